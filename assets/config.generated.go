@@ -1114,6 +1114,7 @@ if __name__ == '__main__':
 # Author(s): Jonas Plum
 
 import argparse
+import json
 
 
 def merge_conditions(list_a, list_b):
@@ -1132,10 +1133,17 @@ class DictListAction(argparse.Action):
     # pylint: disable=too-few-public-methods
 
     def __call__(self, parser, namespace, values, option_string=None):
-        flag = {}
-        for kv in values.split(","):
-            key, value = kv.split("=")
-            flag[key] = value
+        # workflows pass objects as JSON, e.g. {"type": "file"}
+        if values.startswith("{"):
+            try:
+                flag = {key: str(value) for key, value in json.loads(values).items()}
+            except (ValueError, AttributeError) as error:
+                parser.error("invalid %s: %s" % (option_string, error))
+        else:
+            flag = {}
+            for kv in values.split(","):
+                key, value = kv.split("=")
+                flag[key] = value
         if hasattr(namespace, self.dest):
             flags = getattr(namespace, self.dest)
             if flags is not None:
@@ -1158,5 +1166,57 @@ class ScriptArgumentParser(argparse.ArgumentParser):
                 action=DictListAction,
                 metavar="type=file,name=System.evtx...",
                 help="filter processed items")
+`),
+	"/workflows/windows.yml": []byte(`vars:
+  output_dir: .
+
+tasks:
+  hotfixes:
+    command: hotfixes
+    arguments:
+      format: table
+      output: ${output_dir}/hotfixes.txt
+
+  networking:
+    command: networking
+    arguments:
+      format: table
+      output: ${output_dir}/networking.txt
+
+  run_keys:
+    command: run-keys
+    arguments:
+      format: table
+      output: ${output_dir}/run-keys.txt
+
+  services:
+    command: services
+    arguments:
+      format: table
+      output: ${output_dir}/services.txt
+
+  software:
+    command: software
+    arguments:
+      format: table
+      output: ${output_dir}/software.txt
+
+  prefetch:
+    command: prefetch
+    arguments:
+      format: table
+      output: ${output_dir}/prefetch.txt
+
+  usb:
+    command: usb
+    arguments:
+      format: table
+      output: ${output_dir}/usb.txt
+
+  eventlogs:
+    command: eventlogs
+    arguments:
+      format: table
+      output: ${output_dir}/eventlogs.txt
 `),
 }
@@ -22,19 +22,24 @@
 package cmd
 
 import (
-	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
 const appName = "elementary"
 const pluginVersion = "v0.15.0"
 
+// argumentsEnv holds the arguments of script and docker plugins as JSON
+// document, described by the arguments schema of the plugin.
+const argumentsEnv = "ELEMENTARY_ARGUMENTS"
+
 func appDir() string {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
@@ -44,29 +49,21 @@ func appDir() string {
 	return filepath.Join(configDir, appName, pluginVersion)
 }
 
-func readAsCSV(val string) ([]string, error) {
-	if val == "" {
-		return []string{}, nil
-	}
-	stringReader := strings.NewReader(val)
-	csvReader := csv.NewReader(stringReader)
-	return csvReader.Read()
-}
-
+// toCommandlineArgs returns the flags with their values or defaults as
+// command line, lists are passed as one flag per value and objects as JSON.
 func toCommandlineArgs(flagset *pflag.FlagSet, args []string) []string {
 	var cmdArgs []string
 	flagset.VisitAll(func(flag *pflag.Flag) {
 		value := flag.Value.String()
+		if flag.Value.Type() == objectType && value == "" {
+			return
+		}
 
-		endsWithSlice := strings.HasSuffix(flag.Value.Type(), "Slice")
-		if endsWithSlice && strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
-			slice, err := readAsCSV(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
-			if err == nil {
-				for _, value := range slice {
-					cmdArgs = append(cmdArgs, fmt.Sprintf("--%s=%s", flag.Name, value))
-				}
-				return
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range slice.GetSlice() {
+				cmdArgs = append(cmdArgs, fmt.Sprintf("--%s=%s", flag.Name, value))
 			}
+			return
 		}
 		if flag.Value.Type() == "bool" {
 			b, _ := flagset.GetBool(flag.Name)
@@ -81,6 +78,53 @@ func toCommandlineArgs(flagset *pflag.FlagSet, args []string) []string {
 	return cmdArgs
 }
 
+// argumentsDocument returns the arguments of a command as JSON document.
+// Workflow tasks pass typed arguments, otherwise the flags with their values
+// or defaults are used.
+func argumentsDocument(cmd *cobra.Command) (string, error) {
+	arguments, ok := daggy.Arguments(cmd.Context())
+	if !ok {
+		arguments = map[string]interface{}{}
+		flags := cmd.Flags()
+		flags.VisitAll(func(flag *pflag.Flag) {
+			// the help flag is added by cobra and unset objects have no value
+			if flag.Name == "help" || (flag.Value.Type() == objectType && flag.Value.String() == "") {
+				return
+			}
+			var value interface{}
+			var err error
+			switch flag.Value.Type() {
+			case "bool":
+				value, err = flags.GetBool(flag.Name)
+			case "int64":
+				value, err = flags.GetInt64(flag.Name)
+			case "float64":
+				value, err = flags.GetFloat64(flag.Name)
+			case "stringArray":
+				value, err = flags.GetStringArray(flag.Name)
+			case objectType:
+				err = json.Unmarshal([]byte(flag.Value.String()), &value)
+			default:
+				value = flag.Value.String()
+			}
+			if err != nil {
+				value = flag.Value.String()
+			}
+			arguments[flag.Name] = value
+		})
+	}
+	b, err := json.Marshal(arguments)
+	return string(b), err
+}
+
+// annotate sets an annotation on a command.
+func annotate(cmd *cobra.Command, key, value string) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[key] = value
+}
+
 type Property struct {
 	Type        string      `json:"type,omitempty"`
 	Description string      `json:"description,omitempty"`
@@ -113,6 +157,10 @@ func jsonschemaToFlags(schema JSONSchema, command *cobra.Command) error {
 			} else {
 				command.Flags().Int64(name, 0, property.Description)
 			}
+		case "array":
+			command.Flags().StringArray(name, nil, property.Description)
+		case "object":
+			command.Flags().Var(new(objectValue), name, property.Description)
 		case "boolean":
 			if defaultValue, ok := property.Default.(bool); ok {
 				command.Flags().Bool(name, defaultValue, property.Description)
@@ -129,3 +177,27 @@ func jsonschemaToFlags(schema JSONSchema, command *cobra.Command) error {
 	}
 	return nil
 }
+
+// objectType is the type of flags for object properties.
+const objectType = "object"
+
+// objectValue is the value of a flag for an object property, it only
+// accepts JSON objects.
+type objectValue string
+
+func (v *objectValue) String() string {
+	return string(*v)
+}
+
+func (v *objectValue) Set(s string) error {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &object); err != nil {
+		return fmt.Errorf("expected a JSON object: %w", err)
+	}
+	*v = objectValue(s)
+	return nil
+}
+
+func (v *objectValue) Type() string {
+	return objectType
+}
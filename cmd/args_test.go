@@ -0,0 +1,133 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// schemaCommand returns a command with flags for all property types.
+func schemaCommand(t *testing.T) *cobra.Command {
+	command := &cobra.Command{Use: "example"}
+	err := jsonschemaToFlags(JSONSchema{Properties: map[string]Property{
+		"name":    {Type: "string", Default: "x"},
+		"limit":   {Type: "integer"},
+		"verbose": {Type: "boolean"},
+		"names":   {Type: "array"},
+		"options": {Type: "object"},
+	}}, command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return command
+}
+
+func Test_toCommandlineArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"unset flags", nil, []string{"--limit=0", "--name=x", "store"}},
+		{"set flags", []string{"--names", "a", "--names", "b,c", "--verbose", "--limit", "3", "--options", `{"depth":2}`},
+			[]string{"--limit=3", "--name=x", "--names=a", "--names=b,c", `--options={"depth":2}`, "--verbose", "store"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := schemaCommand(t)
+			if err := command.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if got := toCommandlineArgs(command.Flags(), []string{"store"}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toCommandlineArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_objectValue(t *testing.T) {
+	command := schemaCommand(t)
+	if err := command.ParseFlags([]string{"--options", "map[depth:2]"}); err == nil {
+		t.Error("ParseFlags() expected error for object that is not JSON")
+	}
+}
+
+func Test_argumentsDocument(t *testing.T) {
+	typed := map[string]interface{}{
+		"filter": []interface{}{map[interface{}]interface{}{"name": "a,b.txt"}},
+		"limit":  3,
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		args []string
+		want map[string]interface{}
+	}{
+		{"typed arguments", daggy.WithArguments(context.Background(), typed), []string{"--limit", "5"}, map[string]interface{}{
+			"filter": []interface{}{map[string]interface{}{"name": "a,b.txt"}},
+			"limit":  float64(3),
+		}},
+		{"set flags", context.Background(), []string{"--names", "a", "--names", "b,c", "--verbose", "--limit", "3", "--options", `{"depth":2}`},
+			map[string]interface{}{
+				"name":    "x",
+				"names":   []interface{}{"a", "b,c"},
+				"verbose": true,
+				"limit":   float64(3),
+				"options": map[string]interface{}{"depth": float64(2)},
+			}},
+		{"defaults", context.Background(), nil, map[string]interface{}{
+			"name":    "x",
+			"names":   []interface{}{},
+			"verbose": false,
+			"limit":   float64(0),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var document string
+			command := schemaCommand(t)
+			command.RunE = func(cmd *cobra.Command, args []string) (err error) {
+				document, err = argumentsDocument(cmd)
+				return err
+			}
+			command.SetArgs(tt.args)
+			if err := command.ExecuteContext(tt.ctx); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(document), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("argumentsDocument() = %s, want %v", document, tt.want)
+			}
+		})
+	}
+}
@@ -39,9 +39,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/forensicanalysis/forensicworkflows/cmd/subcommands"
+	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
-func dockerCommands() []*cobra.Command {
+func dockerCommands() []func() *cobra.Command {
 	ctx := context.Background()
 	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second) // TODO: adjust time
 	defer cancel()
@@ -58,7 +59,7 @@ func dockerCommands() []*cobra.Command {
 		return nil
 	}
 
-	var commands []*cobra.Command
+	var commands []func() *cobra.Command
 	commandNames := map[string]bool{}
 	for _, imageSummary := range imageSummaries {
 		for _, dockerImage := range imageSummary.RepoTags {
@@ -67,8 +68,12 @@ func dockerCommands() []*cobra.Command {
 				continue
 			}
 
-			cmd := DockerCommand(name, dockerImage, imageSummary.Labels)
-			commands = append(commands, cmd)
+			dockerImage, labels, version := dockerImage, imageSummary.Labels, imageSummary.ID
+			commands = append(commands, func() *cobra.Command {
+				cmd := DockerCommand(name, dockerImage, labels)
+				annotate(cmd, "plugin_version", version)
+				return cmd
+			})
 			commandNames[name] = true
 		}
 	}
@@ -78,8 +83,9 @@ func dockerCommands() []*cobra.Command {
 			continue
 		}
 		if _, ok := commandNames[name]; !ok {
+			dockerImage := dockerImage
 			labels := map[string]string{"short": fmt.Sprintf("Use '%s install -f' to download", os.Args[0])}
-			commands = append(commands, DockerCommand(name, dockerImage, labels))
+			commands = append(commands, func() *cobra.Command { return DockerCommand(name, dockerImage, labels) })
 		}
 	}
 
@@ -102,8 +108,14 @@ func DockerCommand(name, image string, labels map[string]string) *cobra.Command
 			output, teardown := subcommands.NewOutputWriterURL(cmd, args[0])
 			defer teardown()
 
+			arguments, err := argumentsDocument(cmd)
+			if err != nil {
+				return err
+			}
+			env := []string{argumentsEnv + "=" + arguments}
+
 			args = toCommandlineArgs(cmd.Flags(), args)
-			err = docker(image, args, mounts, output)
+			err = docker(cmd.Context(), image, args, env, mounts, output)
 			if err != nil {
 				return err
 			}
@@ -113,19 +125,18 @@ func DockerCommand(name, image string, labels map[string]string) *cobra.Command
 		},
 	}
 
+	annotate(cmd, "plugin_source", "docker "+image)
+
 	if short, ok := labels["short"]; ok {
 		cmd.Short = short + " (docker: " + image + ")"
 	}
 
 	addOutput := true
 	if properties, ok := labels["properties"]; ok {
-		if cmd.Annotations == nil {
-			cmd.Annotations = map[string]string{}
-		}
 		if strings.Contains(properties, "di") { // TODO: use constant
 			addOutput = false
 		}
-		cmd.Annotations["plugin_property_flags"] = properties
+		annotate(cmd, "plugin_property_flags", properties)
 	}
 	setFlags(labels, cmd, addOutput)
 
@@ -192,8 +203,7 @@ func commandName(image string) (string, error) {
 	return "", errors.New("no plugin")
 }
 
-func docker(image string, args []string, mountDirs map[string]string, w io.Writer) error {
-	ctx := context.Background()
+func docker(ctx context.Context, image string, args, env []string, mountDirs map[string]string, w io.Writer) error {
 	cli, err := client.NewEnvClient()
 	if err != nil {
 		return err
@@ -206,7 +216,7 @@ func docker(image string, args []string, mountDirs map[string]string, w io.Write
 
 	resp, err := cli.ContainerCreate(
 		ctx,
-		&container.Config{Image: image, Cmd: args, Tty: true, WorkingDir: "/elementary"},
+		&container.Config{Image: image, Cmd: args, Env: env, Tty: true, WorkingDir: "/elementary"},
 		&container.HostConfig{Binds: mounts},
 		nil,
 		"",
@@ -215,27 +225,62 @@ func docker(image string, args []string, mountDirs map[string]string, w io.Write
 		return err
 	}
 
+	defer removeContainer(cli, resp.ID)
+
 	log.Println("start docker container")
 	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
 		return err
 	}
 
-	defer cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{}) // nolint: errcheck
-
-	go streamLogs(ctx, cli, resp.ID, w, true, false)            // nolint: errcheck
-	go streamLogs(ctx, cli, resp.ID, log.Writer(), false, true) // nolint: errcheck
+	stderr := daggy.LogWriter(ctx)
+	stderrDone := make(chan struct{})
+	go streamLogs(ctx, cli, resp.ID, w, true, false) // nolint: errcheck
+	go func() {
+		defer close(stderrDone)
+		streamLogs(ctx, cli, resp.ID, stderr, false, true) // nolint: errcheck
+	}()
 
 	log.Println("wait for docker container")
 	statusCode, err := cli.ContainerWait(ctx, resp.ID)
+	// the log stream ends with the container or the context, the log writer
+	// must not be closed before
+	<-stderrDone
+	stderr.Close() // nolint: errcheck
 	if err != nil {
 		return err
 	}
 	if statusCode != 0 {
-		return fmt.Errorf("container returned status code %d", statusCode)
+		return &containerError{statusCode: statusCode}
 	}
 	return nil
 }
 
+// containerError is returned if a container exits with a non zero status code.
+type containerError struct {
+	statusCode int64
+}
+
+func (e *containerError) Error() string {
+	return fmt.Sprintf("container returned status code %d", e.statusCode)
+}
+
+// ExitCode returns the status code of the container.
+func (e *containerError) ExitCode() int {
+	return int(e.statusCode)
+}
+
+// removeContainer stops and removes a container. It does not use the task
+// context, because the container must be removed when the task is cancelled.
+func removeContainer(cli *client.Client, id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true})
+	if err != nil {
+		log.Printf("could not remove container %s: %s", id, err)
+	}
+}
+
 func streamLogs(ctx context.Context, cli *client.Client, id string, w io.Writer, stdout, stderr bool) error {
 	options := types.ContainerLogsOptions{ShowStderr: stderr, ShowStdout: stdout, Follow: true}
 	out, err := cli.ContainerLogs(ctx, id, options)
@@ -0,0 +1,98 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// commandExecutor runs tasks with cobra commands. The arguments are passed
+// as command line flags and as typed arguments in the context of the
+// command. Every task runs a new instance of the command, so no flag values
+// carry over from previous tasks.
+type commandExecutor struct {
+	name       string
+	newCommand func() *cobra.Command
+	info       daggy.CommandInfo
+	// out receives the output of the commands, defaults to the standard
+	// output.
+	out io.Writer
+}
+
+// newCommandExecutor creates an executor for the command, the command is
+// described once by the annotations "plugin_version", "plugin_source",
+// "plugin_property_flags" and "plugin_input_filter".
+func newCommandExecutor(newCommand func() *cobra.Command) commandExecutor {
+	command := newCommand()
+	info := daggy.CommandInfo{
+		Version: command.Annotations["plugin_version"],
+		Source:  command.Annotations["plugin_source"],
+		Flags:   command.Flags(),
+	}
+	if properties, ok := command.Annotations["plugin_property_flags"]; ok && strings.Contains(properties, "di") {
+		info.WritesStore = true
+	}
+	if filterset, ok := command.Annotations["plugin_input_filter"]; ok {
+		info.InputFilter = daggy.ParseFilter(filterset)
+	}
+	return commandExecutor{name: command.Name(), newCommand: newCommand, info: info}
+}
+
+// Execute runs a new instance of the command with the task arguments and the
+// forensicstore as command line.
+func (e commandExecutor) Execute(ctx context.Context, store string, spec daggy.TaskSpec) (daggy.Result, error) {
+	command := e.newCommand()
+	if command.RunE == nil {
+		return daggy.Result{}, fmt.Errorf("plugin %s cannot be run", command.Name())
+	}
+
+	// typed arguments are passed next to the command line flags
+	ctx = daggy.WithArguments(ctx, spec.Arguments)
+
+	// execute the command, so the context is passed to the command
+	command.SetArgs(append(spec.CommandLine(), store))
+	if e.out != nil {
+		command.SetOut(e.out)
+	}
+	command.SilenceErrors = true
+	command.SilenceUsage = true
+	err := command.ExecuteContext(ctx)
+
+	var coder interface{ ExitCode() int }
+	if errors.As(err, &coder) {
+		return daggy.Result{ExitCode: coder.ExitCode()}, err
+	}
+	return daggy.Result{}, err
+}
+
+// Info describes the command.
+func (e commandExecutor) Info() daggy.CommandInfo {
+	return e.info
+}
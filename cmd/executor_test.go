@@ -0,0 +1,176 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+func Test_commandExecutor_Info(t *testing.T) {
+	newCommand := func(annotations map[string]string) func() *cobra.Command {
+		return func() *cobra.Command {
+			cmd := &cobra.Command{Use: "example", Annotations: annotations}
+			cmd.Flags().String("output", "", "")
+			return cmd
+		}
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        daggy.CommandInfo
+	}{
+		{"no annotations", nil, daggy.CommandInfo{}},
+		{"direct insert", map[string]string{"plugin_property_flags": "di|im"}, daggy.CommandInfo{WritesStore: true}},
+		{"export", map[string]string{"plugin_property_flags": "ex"}, daggy.CommandInfo{}},
+		{"version", map[string]string{"plugin_version": "v1", "plugin_source": "go built-in"}, daggy.CommandInfo{Version: "v1", Source: "go built-in"}},
+		{"input filter", map[string]string{"plugin_input_filter": "type=file,name=%.pf"}, daggy.CommandInfo{InputFilter: daggy.Filter{{"type": "file", "name": "%.pf"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newCommandExecutor(newCommand(tt.annotations)).Info()
+			if got.Flags == nil || got.Flags.Lookup("output") == nil {
+				t.Errorf("Info() flags = %v, want output flag", got.Flags)
+			}
+			got.Flags = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Info() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_commandExecutor_New(t *testing.T) {
+	created := 0
+	newCommand := func() *cobra.Command {
+		created++
+		return &cobra.Command{Use: "example", RunE: func(cmd *cobra.Command, args []string) error { return nil }}
+	}
+
+	executor := newCommandExecutor(newCommand)
+	executor.Info()
+	executor.Info()
+	if created != 1 {
+		t.Errorf("Info() created %d commands, want the command of the executor", created)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := executor.Execute(context.Background(), "test.forensicstore", daggy.TaskSpec{Name: "a", Command: "example"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if created != 3 {
+		t.Errorf("Execute() created %d commands, want a command per task", created-1)
+	}
+}
+
+func Test_commandExecutor_ExecuteOut(t *testing.T) {
+	newCommand := func() *cobra.Command {
+		return &cobra.Command{Use: "example", RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := fmt.Fprint(cmd.OutOrStdout(), args[0])
+			return err
+		}}
+	}
+
+	out := &bytes.Buffer{}
+	executor := newCommandExecutor(newCommand)
+	executor.out = out
+	if _, err := executor.Execute(context.Background(), "test.forensicstore", daggy.TaskSpec{Name: "a", Command: "example"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "test.forensicstore" {
+		t.Errorf("Execute() output = %q, want test.forensicstore", out.String())
+	}
+}
+
+func Test_commandExecutor_FreshCommands(t *testing.T) {
+	var mux sync.Mutex
+	var filters []string
+	newExport := func() *cobra.Command {
+		var filter []string
+		cmd := &cobra.Command{
+			Use: "export",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				mux.Lock()
+				defer mux.Unlock()
+				filters = append(filters, strings.Join(filter, ";"))
+				return nil
+			},
+		}
+		cmd.Flags().StringArrayVar(&filter, "filter", nil, "")
+		return cmd
+	}
+
+	// the same command with different arguments, in parallel and in series
+	workflow := daggy.Workflow{Tasks: map[string]daggy.Task{
+		"files":     {Command: "export", Arguments: map[string]interface{}{"filter": []interface{}{"type=file"}}},
+		"processes": {Command: "export", Arguments: map[string]interface{}{"filter": []interface{}{"type=process"}}},
+		"all":       {Command: "export", Requires: []string{"files", "processes"}},
+	}, Parallelism: 2, NoCache: true}
+	workflow.SetupGraph()
+
+	if err := workflow.Run(context.Background(), "test.forensicstore", map[string]daggy.Executor{"export": newCommandExecutor(newExport)}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(filters)
+	want := []string{"", "type=file", "type=process"}
+	if !reflect.DeepEqual(filters, want) {
+		t.Errorf("Run() filters = %q, want %q", filters, want)
+	}
+}
+
+func Test_commandExecutor_TypedArguments(t *testing.T) {
+	var got map[string]interface{}
+	plugin := &cobra.Command{Use: "example", RunE: func(cmd *cobra.Command, args []string) error {
+		got, _ = daggy.Arguments(cmd.Context())
+		return nil
+	}}
+	plugin.Flags().StringArray("filter", nil, "")
+	plugin.Flags().Int("limit", 0, "")
+
+	workflow := daggy.Workflow{Tasks: map[string]daggy.Task{"test": {Command: "example", Arguments: map[string]interface{}{
+		"filter": []interface{}{map[interface{}]interface{}{"name": "a,b=c.txt"}},
+		"limit":  3,
+	}}}, NoCache: true}
+	workflow.SetupGraph()
+	if err := workflow.Run(context.Background(), "test.forensicstore", map[string]daggy.Executor{"example": newCommandExecutor(func() *cobra.Command { return plugin })}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"filter": []interface{}{map[string]interface{}{"name": "a,b=c.txt"}},
+		"limit":  3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Arguments() = %#v, want %#v", got, want)
+	}
+}
@@ -65,7 +65,9 @@ func Install() *cobra.Command {
 	return cmd
 }
 
-func ensureSetup() {
+// EnsureSetup installs the scripts and their requirements into the
+// application directory if it does not exist yet.
+func EnsureSetup() {
 	_, err := os.UserConfigDir()
 	if err != nil {
 		log.Printf("config dir not found: %s, using current directory", err)
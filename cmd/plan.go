@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// printPlan writes the execution plan of the workflow without accessing the
+// forensicstore. Hooks are listed before and after the waves of tasks.
+func printPlan(w io.Writer, workflow *daggy.Workflow, store string) error {
+	plan, err := workflow.Plan()
+	if err != nil {
+		return err
+	}
+	hooks, err := workflow.PlanHooks()
+	if err != nil {
+		return err
+	}
+
+	commands := commandFactories()
+	printHooks(w, daggy.HookOnStart, hooks, store, commands)
+	for i, wave := range plan {
+		fmt.Fprintf(w, "wave %d:\n", i+1)
+		for _, step := range wave {
+			printStep(w, step, store, commands)
+			if len(step.Requires) > 0 {
+				fmt.Fprintf(w, "    requires: %s\n", strings.Join(step.Requires, ", "))
+			}
+			if step.When != nil {
+				fmt.Fprintf(w, "    when: %s %s\n", filterString(step.When.Filter), step.When.Count)
+			}
+			if step.Foreach != nil {
+				items := step.Foreach.Items
+				if step.Foreach.Field != "" {
+					items = append(items, fmt.Sprintf("%s of %s", step.Foreach.Field, filterString(step.Foreach.Filter)))
+				}
+				fmt.Fprintf(w, "    foreach: %s\n", strings.Join(items, ", "))
+			}
+		}
+	}
+	for _, point := range []string{daggy.HookOnSuccess, daggy.HookOnFailure, daggy.HookFinally} {
+		printHooks(w, point, hooks, store, commands)
+	}
+	return nil
+}
+
+func printHooks(w io.Writer, point string, hooks map[string][]daggy.Step, store string,
+	commands map[string]func() *cobra.Command) {
+	if len(hooks[point]) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s hooks:\n", point)
+	for _, step := range hooks[point] {
+		printStep(w, step, store, commands)
+	}
+}
+
+// printStep writes the name, the source of the command and the command line
+// of a step.
+func printStep(w io.Writer, step daggy.Step, store string, commands map[string]func() *cobra.Command) {
+	source := "command not found"
+	if newPlugin, ok := commands[step.Command]; ok {
+		plugin := newPlugin()
+		source = plugin.Annotations["plugin_source"]
+		if version, ok := plugin.Annotations["plugin_version"]; ok {
+			source += " " + version
+		}
+	}
+	fmt.Fprintf(w, "  %s (%s)\n", step.Name, source)
+	fmt.Fprintf(w, "    %s\n", commandline(step, store, commands))
+}
+
+// commandline returns the command line a task is executed with. Scripts
+// and docker images receive the arguments as parsed by the command.
+func commandline(step daggy.Step, store string, commands map[string]func() *cobra.Command) string {
+	newPlugin, ok := commands[step.Command]
+	if !ok {
+		return strings.Join(append(append([]string{step.Command}, step.Args...), store), " ")
+	}
+	plugin := newPlugin()
+
+	source := plugin.Annotations["plugin_source"]
+	if !strings.HasPrefix(source, "script ") && !strings.HasPrefix(source, "docker ") {
+		cmdline := append([]string{filepath.Base(os.Args[0]), "run", step.Command}, step.Args...)
+		return strings.Join(append(cmdline, store), " ")
+	}
+
+	if err := plugin.ParseFlags(step.Args); err != nil {
+		return fmt.Sprintf("invalid arguments: %s", err)
+	}
+	args := toCommandlineArgs(plugin.Flags(), []string{store})
+	if strings.HasPrefix(source, "docker ") {
+		return strings.Join(append([]string{"docker", "run", strings.TrimPrefix(source, "docker ")}, args...), " ")
+	}
+	return strings.Join(append([]string{strings.TrimPrefix(source, "script ")}, args...), " ")
+}
+
+func filterString(filter daggy.Filter) string {
+	var conditions []string
+	for _, condition := range filter {
+		var parts []string
+		for attribute, value := range condition {
+			parts = append(parts, attribute+"="+value)
+		}
+		sort.Strings(parts)
+		conditions = append(conditions, strings.Join(parts, ","))
+	}
+	return strings.Join(conditions, " or ")
+}
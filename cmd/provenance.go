@@ -0,0 +1,274 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicstore"
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// provenance is stored as provenance element for every workflow and run
+// invocation, to document how the derived elements of a forensicstore were
+// created.
+type provenance struct {
+	Type          string              `json:"type"`
+	Command       string              `json:"command"`
+	CommandLine   []string            `json:"command_line"`
+	User          string              `json:"user"`
+	Host          string              `json:"host"`
+	Binary        string              `json:"binary"`
+	BinarySHA256  string              `json:"binary_sha256,omitempty"`
+	Version       string              `json:"version"`
+	PluginVersion string              `json:"plugin_version"`
+	Workflow      *workflowProvenance `json:"workflow,omitempty"`
+	Tools         []toolProvenance    `json:"tools"`
+	Run           string              `json:"run,omitempty"`
+	Status        string              `json:"status"`
+	Error         string              `json:"error,omitempty"`
+	StartTime     string              `json:"start_time"`
+	EndTime       string              `json:"end_time"`
+}
+
+// workflowProvenance identifies the workflow file of a workflow invocation.
+type workflowProvenance struct {
+	File   string `json:"file"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Hash is the hash of the task definitions, as in workflow_run
+	// elements.
+	Hash string `json:"hash"`
+}
+
+// toolProvenance identifies a command, the version is the sha256 hash of
+// scripts and the image id of docker images.
+type toolProvenance struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Version string `json:"version"`
+}
+
+// newProvenance describes the current invocation of forensicworkflows.
+func newProvenance(command string) provenance {
+	p := provenance{
+		Type:          "provenance",
+		Command:       command,
+		CommandLine:   os.Args,
+		Version:       "(unknown)",
+		PluginVersion: pluginVersion,
+		Tools:         []toolProvenance{},
+		StartTime:     time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if current, err := user.Current(); err == nil {
+		p.User = current.Username
+	}
+	p.Host, _ = os.Hostname()
+	if binary, err := os.Executable(); err == nil {
+		p.Binary = binary
+		p.BinarySHA256, _ = fileSHA256(binary)
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		p.Version = info.Main.Version
+	}
+	return p
+}
+
+// workflowInvocation describes an invocation of the workflow command.
+func workflowInvocation(workflowFile string, workflow *daggy.Workflow, executors map[string]daggy.Executor) provenance {
+	p := newProvenance("workflow")
+	p.Workflow = &workflowProvenance{File: workflowFile, Hash: workflow.Hash()}
+	p.Workflow.SHA256, _ = fileSHA256(workflowFile)
+
+	commands := map[string]bool{}
+	for _, task := range workflow.Tasks {
+		commands[task.Command] = true
+	}
+	for command := range commands {
+		tool := toolProvenance{Name: command}
+		if executor, ok := executors[command]; ok {
+			if describer, ok := executor.(daggy.Describer); ok {
+				info := describer.Info()
+				tool.Source, tool.Version = info.Source, info.Version
+			}
+		}
+		p.Tools = append(p.Tools, tool)
+	}
+	sort.Slice(p.Tools, func(i, j int) bool { return p.Tools[i].Name < p.Tools[j].Name })
+	return p
+}
+
+// runInvocation describes an invocation of a command with run.
+func runInvocation(cmd *cobra.Command) provenance {
+	p := newProvenance("run")
+	p.Tools = append(p.Tools, toolProvenance{
+		Name:    cmd.Name(),
+		Source:  cmd.Annotations["plugin_source"],
+		Version: cmd.Annotations["plugin_version"],
+	})
+	return p
+}
+
+// record adds the provenance to the forensicstore. Errors are only logged,
+// so a missing provenance does not fail the invocation.
+func (p provenance) record(store string, report *daggy.Report, runErr error) {
+	p.EndTime = time.Now().UTC().Format(time.RFC3339Nano)
+	p.Status = "succeeded"
+	if report != nil {
+		p.Run = report.Run
+		p.Status = string(report.Status)
+	}
+	if runErr != nil {
+		p.Error = runErr.Error()
+		var runError *daggy.RunError
+		if !errors.As(runErr, &runError) || runError.ExitCode() != daggy.ExitOptionalFailures {
+			p.Status = "failed"
+		}
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("could not record provenance: %s", err)
+		return
+	}
+	s, teardown, err := forensicstore.Open(store)
+	if err != nil {
+		log.Printf("could not record provenance: %s", err)
+		return
+	}
+	defer teardown()
+	if _, err := s.Insert(b); err != nil {
+		log.Printf("could not record provenance: %s", err)
+	}
+}
+
+// withProvenance records the provenance of every invocation of the command
+// in the forensicstore it runs on.
+func withProvenance(cmd *cobra.Command) *cobra.Command {
+	runE := cmd.RunE
+	if runE == nil {
+		return cmd
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		p := runInvocation(cmd)
+		err := runE(cmd, args)
+		if len(args) > 0 {
+			p.record(args[0], nil, err)
+		}
+		return err
+	}
+	return cmd
+}
+
+func fileSHA256(path string) (string, error) {
+	b, err := ioutil.ReadFile(path) // #nosec
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
+
+// Provenance is a subcommand to print the provenance history of a
+// forensicstore.
+func Provenance() *cobra.Command {
+	provenanceCmd := &cobra.Command{
+		Use:   "provenance <forensicstore>",
+		Short: "Print the workflow and command invocations on a forensicstore",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Stat(args[0]); err != nil {
+				return err
+			}
+			store, teardown, err := forensicstore.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer teardown()
+
+			elements, err := store.Select(daggy.Filter{{"type": "provenance"}})
+			if err != nil {
+				return err
+			}
+			var history []provenance
+			for _, element := range elements {
+				p := provenance{}
+				if err := json.Unmarshal(element, &p); err != nil {
+					return err
+				}
+				history = append(history, p)
+			}
+			sort.SliceStable(history, func(i, j int) bool {
+				return startTime(history[i]).Before(startTime(history[j]))
+			})
+
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(history)
+			}
+			printProvenance(cmd.OutOrStdout(), history)
+			return nil
+		},
+	}
+	provenanceCmd.Flags().Bool("json", false, "print the provenance elements as JSON")
+	return provenanceCmd
+}
+
+func startTime(p provenance) time.Time {
+	start, _ := time.Parse(time.RFC3339Nano, p.StartTime)
+	return start
+}
+
+func printProvenance(w io.Writer, history []provenance) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Start", "Command", "User", "Host", "Version", "Workflow", "Tools", "Status"})
+	table.SetAutoWrapText(false)
+	for _, p := range history {
+		workflow := ""
+		if p.Workflow != nil {
+			workflow = p.Workflow.File
+		}
+		var tools []string
+		for _, tool := range p.Tools {
+			tools = append(tools, tool.Name+" "+tool.Version)
+		}
+		table.Append([]string{
+			p.StartTime, p.Command, p.User, p.Host, p.Version + " " + p.PluginVersion,
+			workflow, strings.Join(tools, "\n"), p.Status,
+		})
+	}
+	table.Render()
+}
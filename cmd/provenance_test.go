@@ -0,0 +1,106 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestWithProvenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := filepath.Join(dir, "test.forensicstore")
+	insertElement(t, store)
+
+	for _, fail := range []bool{false, true} {
+		fail := fail
+		command := withProvenance(&cobra.Command{
+			Use:         "example",
+			Annotations: map[string]string{"plugin_version": "v1", "plugin_source": "go built-in"},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if fail {
+					return errors.New("broken")
+				}
+				return nil
+			},
+		})
+		command.SetArgs([]string{store})
+		command.SilenceErrors, command.SilenceUsage = true, true
+		if err := command.Execute(); (err != nil) != fail {
+			t.Fatalf("Execute() error = %v, want error %v", err, fail)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	provenanceCmd := Provenance()
+	provenanceCmd.SetOut(out)
+	provenanceCmd.SetArgs([]string{"--json", store})
+	if err := provenanceCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	var history []provenance
+	if err := json.Unmarshal(out.Bytes(), &history); err != nil {
+		t.Fatalf("provenance --json output %q: %s", out.String(), err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("provenance --json got %d elements, want 2", len(history))
+	}
+	for i, wantStatus := range []string{"succeeded", "failed"} {
+		p := history[i]
+		if p.Type != "provenance" || p.Command != "run" || p.Status != wantStatus {
+			t.Errorf("provenance %d = %s %s %s, want provenance run %s", i, p.Type, p.Command, p.Status, wantStatus)
+		}
+		if len(p.Tools) != 1 || p.Tools[0] != (toolProvenance{Name: "example", Source: "go built-in", Version: "v1"}) {
+			t.Errorf("provenance %d tools = %+v", i, p.Tools)
+		}
+		if p.StartTime == "" || p.EndTime == "" || p.PluginVersion != pluginVersion {
+			t.Errorf("provenance %d = %+v", i, p)
+		}
+	}
+	if history[1].Error != "broken" {
+		t.Errorf("provenance error = %q, want broken", history[1].Error)
+	}
+
+	out.Reset()
+	provenanceCmd = Provenance()
+	provenanceCmd.SetOut(out)
+	provenanceCmd.SetArgs([]string{store})
+	if err := provenanceCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "example v1") {
+		t.Errorf("provenance table = %s, want example v1", out.String())
+	}
+}
@@ -25,24 +25,104 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/forensicanalysis/forensicworkflows/cmd/subcommands"
+	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
 // Run is a subcommand to run a single task.
 func Run() *cobra.Command {
-	ensureSetup()
+	EnsureSetup()
 
 	command := &cobra.Command{
 		Use:   "run",
 		Short: "Run single task",
 	}
-	command.AddCommand(allCommands()...)
+	for _, subcommand := range allCommands() {
+		command.AddCommand(withProvenance(subcommand))
+	}
 	return command
 }
 
 func allCommands() []*cobra.Command {
 	var commands []*cobra.Command
-	commands = append(commands, subcommands.Commands()...)
-	commands = append(commands, dockerCommands()...)
-	commands = append(commands, scriptCommands()...)
+	for _, newCommand := range commandFactories() {
+		commands = append(commands, newCommand())
+	}
+	return commands
+}
+
+// commandFactories returns functions that create new instances of all
+// commands by name, so tasks never share flag state. Scripts replace docker
+// images and docker images replace built-in commands with the same name.
+func commandFactories() map[string]func() *cobra.Command {
+	var all []func() *cobra.Command
+	all = append(all, builtinCommands()...)
+	all = append(all, dockerCommands()...)
+	all = append(all, scriptCommands()...)
+	return byName(all)
+}
+
+func builtinCommands() []func() *cobra.Command {
+	var commands []func() *cobra.Command
+	for _, newCommand := range subcommands.Commands() {
+		newCommand := newCommand
+		commands = append(commands, func() *cobra.Command {
+			command := newCommand()
+			annotate(command, "plugin_version", pluginVersion)
+			annotate(command, "plugin_source", "go built-in")
+			return command
+		})
+	}
 	return commands
 }
+
+func byName(commands []func() *cobra.Command) map[string]func() *cobra.Command {
+	factories := map[string]func() *cobra.Command{}
+	for _, newCommand := range commands {
+		factories[newCommand().Name()] = newCommand
+	}
+	return factories
+}
+
+// Executors returns the executors for workflow tasks by command name, e.g. for
+// services that embed the workflow engine. Scripts replace docker images and
+// docker images replace built-in commands with the same name. The scripts are
+// only found after EnsureSetup.
+func Executors() map[string]daggy.Executor {
+	executors := BuiltinExecutors()
+	for name, executor := range DockerExecutors() {
+		executors[name] = executor
+	}
+	for name, executor := range ScriptExecutors() {
+		executors[name] = executor
+	}
+	return executors
+}
+
+// BuiltinExecutors returns the executors for the commands that are built into
+// forensicworkflows by command name.
+func BuiltinExecutors() map[string]daggy.Executor {
+	return commandExecutors(builtinCommands())
+}
+
+// ScriptExecutors returns the executors for the scripts in the application
+// directory by command name, see EnsureSetup to install the scripts.
+func ScriptExecutors() map[string]daggy.Executor {
+	return commandExecutors(scriptCommands())
+}
+
+// DockerExecutors returns the executors for the forensicworkflows docker
+// images by command name. It is empty if docker is not available.
+func DockerExecutors() map[string]daggy.Executor {
+	return commandExecutors(dockerCommands())
+}
+
+// commandExecutors runs the commands as cobra commands by name, later
+// commands replace earlier commands with the same name.
+func commandExecutors(commands []func() *cobra.Command) map[string]daggy.Executor {
+	executors := map[string]daggy.Executor{}
+	for _, newCommand := range commands {
+		executor := newCommandExecutor(newCommand)
+		executors[executor.name] = executor
+	}
+	return executors
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+func TestBuiltinExecutors(t *testing.T) {
+	executors := BuiltinExecutors()
+	executor, ok := executors["export"]
+	if !ok {
+		t.Fatalf("BuiltinExecutors() has no export executor: %v", executors)
+	}
+	describer, ok := executor.(daggy.Describer)
+	if !ok {
+		t.Fatal("export executor does not describe itself")
+	}
+	if info := describer.Info(); info.Source != "go built-in" || info.Version != pluginVersion {
+		t.Errorf("export Info() = %+v, want go built-in %s", info, pluginVersion)
+	}
+}
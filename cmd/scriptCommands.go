@@ -22,6 +22,7 @@
 package cmd
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -34,9 +35,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/forensicanalysis/forensicworkflows/cmd/subcommands"
+	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
-func scriptCommands() []*cobra.Command {
+func scriptCommands() []func() *cobra.Command {
 	scriptDir := filepath.Join(appDir(), "scripts")
 
 	infos, err := ioutil.ReadDir(scriptDir)
@@ -45,11 +47,12 @@ func scriptCommands() []*cobra.Command {
 		return nil
 	}
 
-	var commands []*cobra.Command
+	var commands []func() *cobra.Command
 	for _, info := range infos {
 		validName := strings.HasPrefix(info.Name(), appName+"-") && !strings.HasSuffix(info.Name(), ".info")
 		if info.Mode().IsRegular() && validName {
-			commands = append(commands, scriptCommand(filepath.Join(scriptDir, info.Name())))
+			path := filepath.Join(scriptDir, info.Name())
+			commands = append(commands, func() *cobra.Command { return scriptCommand(path) })
 		}
 	}
 	return commands
@@ -81,6 +84,10 @@ func scriptCommand(path string) *cobra.Command {
 		cmd.Use = filepath.Base(path)
 	}
 	cmd.Short += " (script)"
+	annotate(cmd.Command, "plugin_source", "script "+path)
+	if script, err := ioutil.ReadFile(path); err == nil { // #nosec
+		annotate(cmd.Command, "plugin_version", fmt.Sprintf("%s+sha256:%x", pluginVersion, sha256.Sum256(script)))
+	}
 	cmd.Args = subcommands.RequireStore
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		log.Printf("run %s %s", cmd.Name(), args[0])
@@ -96,17 +103,27 @@ func scriptCommand(path string) *cobra.Command {
 			}
 		}
 
+		// exec replaces the shell, so cancelling the context stops the script itself
+		shellCommand = "exec " + shellCommand
+
 		log.Println("sh", "-c", shellCommand)
-		script := exec.Command("sh", "-c", shellCommand) // #nosec
+		script := exec.CommandContext(cmd.Context(), "sh", "-c", shellCommand) // #nosec
 
 		output, teardown := subcommands.NewOutputWriterURL(cmd, args[0])
 		defer teardown()
 
+		arguments, err := argumentsDocument(cmd)
+		if err != nil {
+			return err
+		}
+		script.Env = append(os.Environ(), argumentsEnv+"="+arguments)
 		script.Stdout = output
-		script.Stderr = log.Writer()
-		err := script.Run()
+		stderr := daggy.LogWriter(cmd.Context())
+		script.Stderr = stderr
+		err = script.Run()
+		stderr.Close() // nolint: errcheck
 		if err != nil {
-			return fmt.Errorf("%s script failed with %s", cmd.Use, err)
+			return fmt.Errorf("%s script failed with %w", cmd.Use, err)
 		}
 
 		output.WriteFooter()
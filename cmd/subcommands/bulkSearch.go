@@ -31,6 +31,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/forensicanalysis/forensicstore"
+	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
 func BulkSearch() *cobra.Command {
@@ -40,7 +41,7 @@ func BulkSearch() *cobra.Command {
 		Short: "Bulk search indicators",
 		Args:  RequireStore,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			log.Printf("run bulk-search %s", args)
+			daggy.Logf(cmd.Context(), "run bulk-search %s", args)
 
 			store, teardown, err := forensicstore.Open(args[0])
 			if err != nil {
@@ -58,11 +59,15 @@ func BulkSearch() *cobra.Command {
 
 			scanner := bufio.NewScanner(file)
 			for scanner.Scan() {
+				if err := cmd.Context().Err(); err != nil {
+					return err
+				}
+
 				ioc := scanner.Text()
 				if ioc == "" {
 					continue
 				}
-				log.Println("search", ioc)
+				daggy.Logf(cmd.Context(), "search %s", ioc)
 
 				element, err := getSearchCount(store.Connection(), ioc)
 				if err != nil {
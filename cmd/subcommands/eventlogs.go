@@ -24,7 +24,6 @@ package subcommands
 import (
 	"encoding/json"
 	"io"
-	"log"
 
 	"github.com/Velocidex/ordereddict"
 	"github.com/spf13/cobra"
@@ -42,9 +41,10 @@ func Eventlogs() *cobra.Command {
 		Short: "Process eventlogs into single events",
 		Args:  RequireStore,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			log.Printf("run eventlogs %s", args)
-			return eventlogsFromStore(args[0], extractFilter(filtersets), cmd)
+			daggy.Logf(cmd.Context(), "run eventlogs %s", args)
+			return eventlogsFromStore(args[0], getFilter(cmd, filtersets), cmd)
 		},
+		Annotations: map[string]string{"plugin_input_filter": "type=file,name=%.evtx"},
 	}
 	AddOutputFlags(eventlogsCmd)
 	eventlogsCmd.Flags().StringArrayVar(&filtersets, "filter", nil, "filter processed events")
@@ -83,7 +83,11 @@ func eventlogsFromStore(url string, filter daggy.Filter, cmd *cobra.Command) err
 			"System.Provider.Name",
 		},
 	})
-	for _, element := range fileElements {
+	for i, element := range fileElements {
+		if err := cmd.Context().Err(); err != nil {
+			return err
+		}
+
 		exportPath := gjson.GetBytes(element, "export_path")
 		if exportPath.Exists() && exportPath.String() != "" {
 			r, err := fileToReader(store, exportPath)
@@ -100,6 +104,7 @@ func eventlogsFromStore(url string, filter daggy.Filter, cmd *cobra.Command) err
 				output.writeLine(event) // nolint: errcheck
 			}
 		}
+		daggy.ReportProgress(cmd.Context(), int64(i+1), int64(len(fileElements)))
 	}
 	output.WriteFooter()
 	return nil
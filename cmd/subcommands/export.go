@@ -35,7 +35,7 @@ func Export() *cobra.Command {
 		Short: "Export selected elements",
 		Args:  RequireStore,
 		RunE: func(rcmd *cobra.Command, args []string) error {
-			filter := extractFilter(filtersets)
+			filter := getFilter(rcmd, filtersets)
 
 			store, teardown, err := forensicstore.Open(args[0])
 			if err != nil {
@@ -43,7 +43,7 @@ func Export() *cobra.Command {
 			}
 			defer teardown()
 
-			elements, err := store.Select(filter)
+			elements, err := filter.Select(store)
 			if err != nil {
 				return err
 			}
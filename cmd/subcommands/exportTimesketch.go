@@ -24,7 +24,6 @@ package subcommands
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
@@ -42,7 +41,7 @@ func ExportTimesketch() *cobra.Command {
 		Short: "Export in timesketch jsonl format",
 		Args:  RequireStore,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return exportStore(args[0], extractFilter(filtersets), cmd)
+			return exportStore(args[0], getFilter(cmd, filtersets), cmd)
 		},
 		Annotations: map[string]string{"plugin_property_flags": "ex"},
 	}
@@ -58,7 +57,7 @@ func exportStore(url string, filter daggy.Filter, cmd *cobra.Command) error {
 	}
 	defer teardown()
 
-	elements, err := store.Select(filter)
+	elements, err := filter.Select(store)
 	if err != nil {
 		return err
 	}
@@ -92,7 +91,7 @@ func exportStore(url string, filter daggy.Filter, cmd *cobra.Command) error {
 					TimestampDesc: field,
 				})
 				if err != nil {
-					log.Println(err)
+					daggy.Logf(cmd.Context(), "%s", err)
 					return true
 				}
 				output.writeLine(b) // nolint: errcheck
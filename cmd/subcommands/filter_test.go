@@ -0,0 +1,64 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package subcommands
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+func Test_getFilter(t *testing.T) {
+	typed := daggy.WithArguments(context.Background(), map[string]interface{}{
+		"filter": []interface{}{map[interface{}]interface{}{"name": "a,b.txt"}},
+	})
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		filtersets []string
+		want       daggy.Filter
+	}{
+		{"flags", context.Background(), []string{"type=file,name=a"}, daggy.Filter{{"type": "file", "name": "a"}}},
+		{"typed", typed, []string{"name=a", "b.txt"}, daggy.Filter{{"name": "a,b.txt"}}},
+		{"typed without filter", daggy.WithArguments(context.Background(), nil), []string{"type=file"}, daggy.Filter{{"type": "file"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got daggy.Filter
+			cmd := &cobra.Command{Use: "test", Run: func(cmd *cobra.Command, args []string) {
+				got = getFilter(cmd, tt.filtersets)
+			}}
+			cmd.SetArgs([]string{})
+			if err := cmd.ExecuteContext(tt.ctx); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
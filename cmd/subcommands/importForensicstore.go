@@ -41,8 +41,8 @@ func ForensicStoreImport() *cobra.Command {
 		Use:   "import-forensicstore <forensicstore>",
 		Short: "Import forensicstore files",
 		Args:  RequireStore,
-		RunE: func(_ *cobra.Command, args []string) error {
-			return singleImport(args[0], file, extractFilter(filtersets))
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return singleImport(args[0], file, getFilter(cmd, filtersets))
 		},
 		Annotations: map[string]string{"plugin_property_flags": "di|im"},
 	}
@@ -38,8 +38,8 @@ func JSONImport() *cobra.Command {
 		Use:   "import-json <forensicstore>",
 		Short: "Import json files",
 		Args:  RequireStore,
-		RunE: func(_ *cobra.Command, args []string) error {
-			filter := extractFilter(filtersets)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := getFilter(cmd, filtersets)
 
 			store, teardown, err := forensicstore.Open(args[0])
 			if err != nil {
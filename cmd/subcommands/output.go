@@ -27,7 +27,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"strings"
 
@@ -36,6 +35,7 @@ import (
 	"github.com/tidwall/gjson"
 
 	"github.com/forensicanalysis/forensicstore"
+	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
 type format int
@@ -68,6 +68,8 @@ type OutputWriter struct {
 	firstLine    bool
 	moreElements bool
 	cmd          *cobra.Command
+	// file is the output file opened for the output flag
+	file io.Closer
 
 	buffer *bytes.Buffer
 
@@ -76,7 +78,7 @@ type OutputWriter struct {
 }
 
 func newOutputWriter(store *forensicstore.ForensicStore, cmd *cobra.Command) *OutputWriter {
-	format, addToStore := parseOutputFlags(cmd)
+	format, addToStore, file := parseOutputFlags(cmd)
 	outStore := store
 	if !addToStore {
 		outStore = nil
@@ -86,6 +88,7 @@ func newOutputWriter(store *forensicstore.ForensicStore, cmd *cobra.Command) *Ou
 		format: format,
 		store:  outStore,
 		cmd:    cmd,
+		file:   file,
 		buffer: &bytes.Buffer{},
 	}
 
@@ -127,10 +130,10 @@ func (o *OutputWriter) writeHeaderLine(line []byte) {
 	config := &outputConfig{}
 	err := json.Unmarshal(line, config)
 	if err != nil || len(config.Header) == 0 {
-		log.Printf("could not unmarshal config: %s, '%s'", err, line)
+		daggy.Logf(o.cmd.Context(), "could not unmarshal config: %s, '%s'", err, line)
 		_, err = fmt.Fprintln(o.cmd.OutOrStdout(), string(line))
 		if err != nil {
-			log.Println(err)
+			daggy.Logf(o.cmd.Context(), "%s", err)
 		}
 		return
 	}
@@ -148,11 +151,11 @@ func (o *OutputWriter) writeHeaderConfig(outConfig *outputConfig) {
 	case csvFormat:
 		err := o.csvWriter.Write(o.config.Header)
 		if err != nil {
-			log.Println(err)
+			daggy.Logf(o.cmd.Context(), "%s", err)
 		}
 	case jsonlFormat, noneFormat, jsonFormat:
 	default:
-		log.Println("unknown output format:", o.format)
+		daggy.Logf(o.cmd.Context(), "unknown output format: %v", o.format)
 	}
 }
 
@@ -188,7 +191,7 @@ func (o *OutputWriter) writeLine(element []byte) { // nolint: gocyclo
 		(o.format == csvFormat && o.config == nil):
 		_, err := fmt.Fprintln(o.cmd.OutOrStdout(), string(element))
 		if err != nil {
-			log.Println(err)
+			daggy.Logf(o.cmd.Context(), "%s", err)
 		}
 	case o.format == tableFormat:
 		o.tableWriter.Append(o.getColumns(element))
@@ -196,7 +199,7 @@ func (o *OutputWriter) writeLine(element []byte) { // nolint: gocyclo
 		err := o.csvWriter.Write(o.getColumns(element))
 		if err != nil {
 			fmt.Fprintln(o.cmd.OutOrStdout(), string(element)) // nolint: errcheck
-			log.Println(err)
+			daggy.Logf(o.cmd.Context(), "%s", err)
 		}
 	case o.format == jsonFormat:
 		if o.moreElements {
@@ -211,7 +214,7 @@ func (o *OutputWriter) writeLine(element []byte) { // nolint: gocyclo
 	if o.store != nil {
 		_, err := o.store.Insert(element)
 		if err != nil {
-			log.Println(err, string(element))
+			daggy.Logf(o.cmd.Context(), "%s %s", err, element)
 		}
 	}
 }
@@ -243,9 +246,10 @@ func (o *OutputWriter) WriteFooter() {
 		o.cmd.OutOrStdout().Write([]byte("]")) // nolint: errcheck
 	}
 
-	out := o.cmd.OutOrStdout()
-	if closer, ok := out.(io.Closer); ok && out != os.Stdout {
-		closer.Close()
+	// only the output file is closed, the output of the command can be
+	// shared, e.g. the standard error of the workflow command
+	if o.file != nil {
+		o.file.Close()
 	}
 }
 
@@ -263,29 +267,32 @@ func AddOutputFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("add-to-store", false, "additionally save output to store")
 }
 
-func parseOutputFlags(cmd *cobra.Command) (format, bool) {
+func parseOutputFlags(cmd *cobra.Command) (format, bool, io.Closer) {
 	output, err := cmd.Flags().GetString("output")
 	if err != nil {
-		log.Println(err)
+		daggy.Logf(cmd.Context(), "%s", err)
 	}
+	var file io.Closer
 	if output != "" {
 		f, err := os.Create(output)
 		if err != nil {
-			log.Println(err)
+			daggy.Logf(cmd.Context(), "%s", err)
+		} else {
+			cmd.SetOut(f)
+			file = f
 		}
-		cmd.SetOut(f)
 	}
 
 	formatString, err := cmd.Flags().GetString("format")
 	if err != nil {
-		log.Println(err)
+		daggy.Logf(cmd.Context(), "%s", err)
 	}
 	format := fromString(formatString)
 
 	addToStore, err := cmd.Flags().GetBool("add-to-store")
 	if err != nil {
-		log.Println(err)
+		daggy.Logf(cmd.Context(), "%s", err)
 	}
 
-	return format, addToStore
+	return format, addToStore, file
 }
@@ -23,7 +23,6 @@ package subcommands
 
 import (
 	"encoding/json"
-	"log"
 
 	"github.com/spf13/cobra"
 	"github.com/tidwall/gjson"
@@ -40,9 +39,10 @@ func Prefetch() *cobra.Command {
 		Short: "Process prefetch files",
 		Args:  RequireStore,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			log.Printf("run prefetch %s", args)
-			return prefetchFromStore(args[0], extractFilter(filtersets), cmd)
+			daggy.Logf(cmd.Context(), "run prefetch %s", args)
+			return prefetchFromStore(args[0], getFilter(cmd, filtersets), cmd)
 		},
+		Annotations: map[string]string{"plugin_input_filter": "type=file,name=%.pf"},
 	}
 	AddOutputFlags(prefetchCommand)
 	prefetchCommand.Flags().StringArrayVar(&filtersets, "filter", nil, "filter processed events")
@@ -82,7 +82,11 @@ func prefetchFromStore(url string, filter daggy.Filter, cmd *cobra.Command) erro
 		},
 	})
 
-	for _, element := range fileElements {
+	for i, element := range fileElements {
+		if err := cmd.Context().Err(); err != nil {
+			return err
+		}
+
 		exportPath := gjson.GetBytes(element, "export_path")
 		if exportPath.Exists() && exportPath.String() != "" {
 			buff, err := fileToReader(store, exportPath)
@@ -102,6 +106,7 @@ func prefetchFromStore(url string, filter daggy.Filter, cmd *cobra.Command) erro
 
 			output.writeLine(elem) // nolint: errcheck
 		}
+		daggy.ReportProgress(cmd.Context(), int64(i+1), int64(len(fileElements)))
 	}
 
 	output.WriteFooter()
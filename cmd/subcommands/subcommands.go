@@ -36,18 +36,18 @@ import (
 	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
-// Commands returns a map of all implemented commands.
-func Commands() []*cobra.Command {
-	return []*cobra.Command{
-		Eventlogs(),
-		Export(),
-		ForensicStoreImport(),
-		JSONImport(),
-		Prefetch(),
-		ImportFile(),
-		// Yara(),
-		ExportTimesketch(),
-		BulkSearch(),
+// Commands returns the constructors of all implemented commands.
+func Commands() []func() *cobra.Command {
+	return []func() *cobra.Command{
+		Eventlogs,
+		Export,
+		ForensicStoreImport,
+		JSONImport,
+		Prefetch,
+		ImportFile,
+		// Yara,
+		ExportTimesketch,
+		BulkSearch,
 	}
 }
 
@@ -61,6 +61,17 @@ func RequireStore(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+// getFilter returns the typed filter argument of a workflow task or parses
+// the filter flags, e.g. "type=file,name=%.evtx".
+func getFilter(cmd *cobra.Command, filtersets []string) daggy.Filter {
+	if arguments, ok := daggy.Arguments(cmd.Context()); ok {
+		if filter, err := daggy.ToFilter(arguments["filter"]); err == nil && filter != nil {
+			return filter
+		}
+	}
+	return extractFilter(filtersets)
+}
+
 func extractFilter(filtersets []string) daggy.Filter {
 	filter := daggy.Filter{}
 	for _, filterset := range filtersets {
@@ -0,0 +1,333 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// States of watched forensicstores.
+const (
+	watchRunning = "running"
+	watchDone    = "done"
+	watchFailed  = "failed"
+)
+
+// Watch is a subcommand to run a workflow on every forensicstore that
+// arrives in a directory.
+func Watch() *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Run a workflow on new forensicstores in a directory",
+		Long: `watch polls a directory for new forensicstores. A forensicstore is processed as
+soon as it did not change for the stable duration. Processed forensicstores are moved
+to the done or failed subdirectory. The processed forensicstores are recorded in a
+state file, so they are not processed again after a restart. Forensicstores that were
+interrupted are resumed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			interval, _ := cmd.Flags().GetDuration("interval")
+			if interval <= 0 {
+				return fmt.Errorf("interval must be positive, got %s", interval)
+			}
+			stable, _ := cmd.Flags().GetDuration("stable")
+			if stable < 0 {
+				return fmt.Errorf("stable duration must not be negative, got %s", stable)
+			}
+
+			workflowFile, _ := cmd.Flags().GetString("file")
+			workflow, err := daggy.ParseWithBuiltins(workflowFile, builtinWorkflows())
+			if err != nil {
+				return fmt.Errorf("parsing failed: %w", err)
+			}
+			workflow.SetupGraph()
+			if err := workflow.Validate(); err != nil {
+				return err
+			}
+
+			dir, _ := cmd.Flags().GetString("dir")
+			stateFile, _ := cmd.Flags().GetString("state")
+			if stateFile == "" {
+				stateFile = filepath.Join(dir, ".watch.json")
+			}
+			w := &watcher{
+				dir:          dir,
+				workflowFile: workflowFile,
+				stateFile:    stateFile,
+				workflow:     workflow,
+				stable:       stable,
+				pending:      map[string]pendingStore{},
+			}
+			if err := w.load(); err != nil {
+				return err
+			}
+
+			log.Printf("watch %s", dir)
+			commands := Executors()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				if err := w.poll(cmd.Context(), commands); err != nil {
+					return err
+				}
+				select {
+				case <-ticker.C:
+				case <-cmd.Context().Done():
+					return nil
+				}
+			}
+		},
+	}
+	watchCmd.Flags().StringP("file", "f", "", "workflow definition file")
+	watchCmd.Flags().String("dir", "", "directory that receives forensicstores")
+	watchCmd.Flags().Duration("stable", 30*time.Second, "time a forensicstore must not change before it is processed")
+	watchCmd.Flags().Duration("interval", 5*time.Second, "time between checks of the directory")
+	watchCmd.Flags().String("state", "", "file that records the processed forensicstores (default <dir>/.watch.json)")
+	_ = watchCmd.MarkFlagRequired("file")
+	_ = watchCmd.MarkFlagRequired("dir")
+	return watchCmd
+}
+
+// storeState is recorded in the state file for every processed
+// forensicstore.
+type storeState struct {
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// pendingStore is a forensicstore that waits to become stable.
+type pendingStore struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+type watcher struct {
+	dir          string
+	stateFile    string
+	workflowFile string
+	workflow     *daggy.Workflow
+	stable       time.Duration
+
+	stores  map[string]storeState
+	pending map[string]pendingStore
+}
+
+// load reads the state file, if it exists.
+func (w *watcher) load() error {
+	w.stores = map[string]storeState{}
+	b, err := ioutil.ReadFile(w.stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read state: %w", err)
+	}
+	if err := json.Unmarshal(b, &w.stores); err != nil {
+		return fmt.Errorf("could not read state %s: %w", w.stateFile, err)
+	}
+	return nil
+}
+
+// save replaces the state file.
+func (w *watcher) save() error {
+	b, err := json.MarshalIndent(w.stores, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := w.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil { // #nosec
+		return fmt.Errorf("could not write state: %w", err)
+	}
+	return os.Rename(tmp, w.stateFile)
+}
+
+// poll processes all forensicstores in the directory that are stable.
+func (w *watcher) poll(ctx context.Context, commands map[string]daggy.Executor) error {
+	matches, err := filepath.Glob(filepath.Join(w.dir, "*.forensicstore"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(matches)
+
+	now := time.Now()
+	seen := map[string]bool{}
+	for _, store := range matches {
+		name := filepath.Base(store)
+		seen[name] = true
+
+		size, modTime, err := storeSize(store)
+		if err != nil {
+			log.Printf("could not check %s: %s", store, err)
+			continue
+		}
+		if w.done(name, size, modTime) {
+			continue
+		}
+
+		pending, ok := w.pending[name]
+		if !ok || pending.size != size || !pending.modTime.Equal(modTime) {
+			w.pending[name] = pendingStore{size: size, modTime: modTime, since: now}
+			continue
+		}
+		if now.Sub(pending.since) < w.stable {
+			continue
+		}
+
+		delete(w.pending, name)
+		if err := w.process(ctx, commands, store, pending); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	for name := range w.pending {
+		if !seen[name] {
+			delete(w.pending, name)
+		}
+	}
+	return nil
+}
+
+// done checks if a forensicstore with this size and modification time was
+// already processed.
+func (w *watcher) done(name string, size int64, modTime time.Time) bool {
+	state, ok := w.stores[name]
+	return ok && state.Status != watchRunning && state.Size == size && state.ModTime.Equal(modTime)
+}
+
+// process runs the workflow on a stable forensicstore and moves it to the
+// done or failed directory. Interrupted runs are resumed.
+func (w *watcher) process(ctx context.Context, commands map[string]daggy.Executor, store string,
+	stable pendingStore) error {
+	name := filepath.Base(store)
+	workflow := *w.workflow
+	workflow.Resume = w.stores[name].Status == watchRunning
+
+	state := storeState{Size: stable.size, ModTime: stable.modTime, Status: watchRunning, StartTime: time.Now().UTC()}
+	w.stores[name] = state
+	if err := w.save(); err != nil {
+		return err
+	}
+
+	log.Printf("process %s", store)
+	invocation := workflowInvocation(w.workflowFile, &workflow, commands)
+	invocation.Command = "watch"
+	report, err := workflow.RunWithReport(ctx, store, commands)
+	invocation.record(store, report, err)
+	if ctx.Err() != nil {
+		log.Printf("processing %s interrupted", store)
+		return nil
+	}
+
+	state.Status = watchDone
+	var runErr *daggy.RunError
+	if err != nil && !(errors.As(err, &runErr) && runErr.ExitCode() == daggy.ExitOptionalFailures) {
+		state.Status = watchFailed
+		state.Error = err.Error()
+		log.Printf("processing %s failed: %s", store, err)
+	}
+
+	// the workflow changes the forensicstore, so it is recorded as it is now
+	if size, modTime, err := storeSize(store); err == nil {
+		state.Size, state.ModTime = size, modTime
+	}
+	state.Path, err = moveStore(store, filepath.Join(w.dir, state.Status))
+	if err != nil {
+		log.Printf("could not move %s: %s", store, err)
+		message := fmt.Sprintf("could not move: %s", err)
+		if state.Error != "" {
+			message = state.Error + "; " + message
+		}
+		state.Error = message
+	}
+	state.EndTime = time.Now().UTC()
+	w.stores[name] = state
+	return w.save()
+}
+
+// storeSize returns the size and the latest modification of a forensicstore
+// and the directory of its files.
+func storeSize(store string) (int64, time.Time, error) {
+	var size int64
+	var modTime time.Time
+	for _, path := range []string{store, strings.TrimSuffix(store, ".forensicstore")} {
+		err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+			if info.ModTime().After(modTime) {
+				modTime = info.ModTime()
+			}
+			return nil
+		})
+		if err != nil && !(os.IsNotExist(err) && path != store) {
+			return 0, time.Time{}, err
+		}
+	}
+	return size, modTime, nil
+}
+
+// moveStore moves a forensicstore and the directory of its files into the
+// directory and returns the new path.
+func moveStore(store, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, filepath.Base(store))
+	if _, err := os.Stat(target); err == nil {
+		return "", fmt.Errorf("%s already exists", target)
+	}
+	if err := os.Rename(store, target); err != nil {
+		return "", err
+	}
+
+	files := strings.TrimSuffix(store, ".forensicstore")
+	if _, err := os.Stat(files); err == nil {
+		if err := os.Rename(files, strings.TrimSuffix(target, ".forensicstore")); err != nil {
+			return target, err
+		}
+	}
+	return target, nil
+}
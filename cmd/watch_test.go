@@ -0,0 +1,233 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forensicanalysis/forensicstore"
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+// fakeRuns counts the task executions per forensicstore, tasks fail on
+// forensicstores named bad.
+type fakeRuns struct {
+	mux  sync.Mutex
+	runs map[string]int
+}
+
+func (f *fakeRuns) executors() map[string]daggy.Executor {
+	return map[string]daggy.Executor{"check": daggy.ExecutorFunc(func(ctx context.Context, store string, spec daggy.TaskSpec) (daggy.Result, error) {
+		f.mux.Lock()
+		defer f.mux.Unlock()
+		f.runs[filepath.Base(store)]++
+		if strings.HasPrefix(filepath.Base(store), "bad") {
+			return daggy.Result{ExitCode: 1}, errors.New("broken")
+		}
+		return daggy.Result{}, nil
+	})}
+}
+
+func newTestWatcher(t *testing.T, dir string, stable time.Duration) *watcher {
+	workflow := &daggy.Workflow{Tasks: map[string]daggy.Task{"check": {Command: "check"}}, NoCache: true}
+	workflow.SetupGraph()
+	w := &watcher{
+		dir:          dir,
+		workflowFile: "test.yml",
+		stateFile:    filepath.Join(dir, ".watch.json"),
+		workflow:     workflow,
+		stable:       stable,
+		pending:      map[string]pendingStore{},
+	}
+	if err := w.load(); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// insertElement adds an element to the forensicstore, the forensicstore is
+// created if it does not exist.
+func insertElement(t *testing.T, store string) {
+	open := forensicstore.Open
+	if !exists(store) {
+		open = forensicstore.New
+	}
+	s, teardown, err := open(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	if _, err := s.Insert(forensicstore.JSONElement(`{"type": "test", "name": "a.evtx"}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestWatcher_poll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	fake := &fakeRuns{runs: map[string]int{}}
+	commands := fake.executors()
+
+	poll := func(w *watcher) {
+		if err := w.poll(ctx, commands); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// forensicstores are processed once they did not change between polls
+	insertElement(t, filepath.Join(dir, "good.forensicstore"))
+	insertElement(t, filepath.Join(dir, "bad.forensicstore"))
+	w := newTestWatcher(t, dir, 0)
+	poll(w)
+	if len(fake.runs) != 0 {
+		t.Fatalf("poll() processed unstable forensicstores: %v", fake.runs)
+	}
+	insertElement(t, filepath.Join(dir, "good.forensicstore"))
+	poll(w)
+	if fake.runs["good.forensicstore"] != 0 || fake.runs["bad.forensicstore"] != 1 {
+		t.Fatalf("poll() runs = %v, want only bad.forensicstore", fake.runs)
+	}
+	poll(w)
+	if fake.runs["good.forensicstore"] != 1 {
+		t.Fatalf("poll() runs = %v, want good.forensicstore", fake.runs)
+	}
+	for _, path := range []string{"done/good.forensicstore", "failed/bad.forensicstore"} {
+		if !exists(filepath.Join(dir, path)) {
+			t.Errorf("poll() did not move forensicstore to %s", path)
+		}
+	}
+
+	// the state survives a restart
+	w = newTestWatcher(t, dir, 0)
+	if w.stores["good.forensicstore"].Status != watchDone || w.stores["bad.forensicstore"].Status != watchFailed {
+		t.Errorf("load() stores = %+v", w.stores)
+	}
+	if w.stores["bad.forensicstore"].Error == "" {
+		t.Error("load() failed forensicstore without error")
+	}
+
+	// forensicstores are not processed before the stable duration passed
+	insertElement(t, filepath.Join(dir, "late.forensicstore"))
+	slow := newTestWatcher(t, dir, time.Hour)
+	poll(slow)
+	poll(slow)
+	if fake.runs["late.forensicstore"] != 0 {
+		t.Errorf("poll() processed late.forensicstore before it was stable")
+	}
+	poll(w)
+	poll(w)
+	if fake.runs["late.forensicstore"] != 1 {
+		t.Errorf("poll() runs = %v, want late.forensicstore", fake.runs)
+	}
+
+	// a forensicstore with the name of a processed one stays in place
+	insertElement(t, filepath.Join(dir, "good.forensicstore"))
+	poll(w)
+	poll(w)
+	poll(w)
+	if fake.runs["good.forensicstore"] != 2 {
+		t.Errorf("poll() runs = %v, want good.forensicstore twice", fake.runs)
+	}
+	state := w.stores["good.forensicstore"]
+	if !exists(filepath.Join(dir, "good.forensicstore")) || !strings.Contains(state.Error, "already exists") {
+		t.Errorf("poll() state = %+v, want collision error", state)
+	}
+}
+
+func TestWatcher_pollResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	fake := &fakeRuns{runs: map[string]int{}}
+	commands := fake.executors()
+
+	// the task succeeded before the watcher was interrupted
+	insertElement(t, filepath.Join(dir, "a.forensicstore"))
+	store := filepath.Join(dir, "a.forensicstore")
+	w := newTestWatcher(t, dir, 0)
+	if err := w.workflow.Run(ctx, store, commands); err != nil {
+		t.Fatal(err)
+	}
+	size, modTime, err := storeSize(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.stores["a.forensicstore"] = storeState{Size: size, ModTime: modTime, Status: watchRunning}
+	if err := w.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	w = newTestWatcher(t, dir, 0)
+	for i := 0; i < 2; i++ {
+		if err := w.poll(ctx, commands); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.runs["a.forensicstore"] != 1 {
+		t.Errorf("poll() runs = %v, want the task to be resumed", fake.runs)
+	}
+	if w.stores["a.forensicstore"].Status != watchDone || !exists(filepath.Join(dir, "done", "a.forensicstore")) {
+		t.Errorf("poll() state = %+v, want done", w.stores["a.forensicstore"])
+	}
+}
+
+func TestWatch_durations(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"zero interval", []string{"--interval", "0"}},
+		{"negative interval", []string{"--interval", "-1s"}},
+		{"negative stable", []string{"--stable", "-1s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := Watch()
+			command.SetArgs(append([]string{"--file", "missing.yml", "--dir", "missing"}, tt.args...))
+			command.SilenceErrors, command.SilenceUsage = true, true
+			err := command.Execute()
+			if err == nil || !strings.Contains(err.Error(), "must") {
+				t.Errorf("Execute() error = %v, want invalid duration", err)
+			}
+		})
+	}
+}
@@ -22,44 +22,342 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"strings"
+	"time"
 
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
-	"github.com/forensicanalysis/forensicworkflows/cmd/subcommands"
+	"github.com/forensicanalysis/forensicworkflows/assets"
 	"github.com/forensicanalysis/forensicworkflows/daggy"
 )
 
 // Workflow is a subcommand to run a forensic workflow.
 func Workflow() *cobra.Command {
 	workflowCmd := &cobra.Command{
-		Use:   "workflow",
+		Use:   "workflow <forensicstore>...",
 		Short: "Run a workflow",
 		Long: `process can run parallel workflows locally. Those workflows are a directed acyclic graph of tasks.
-Those tasks can be defined to be run on the system itself or in a containerized way.`,
-		Args: subcommands.RequireStore,
+Those tasks can be defined to be run on the system itself or in a containerized way.
+Multiple forensicstores, glob patterns or directories containing forensicstores can be given
+to run the workflow on each of them.`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// parse workflow yaml
-			workflowFile, _ := cmd.Flags().GetString("file")
-			if _, err := os.Stat(workflowFile); os.IsNotExist(err) {
-				log.Fatal(err, workflowFile)
+			workflowFile, workflow, err := setupWorkflow(cmd)
+			if err != nil {
+				return err
 			}
-			workflow, err := daggy.Parse(workflowFile)
+			stores, err := daggy.ExpandStores(args)
 			if err != nil {
-				log.Fatal("parsing failed: ", err)
+				return err
 			}
-
-			plugins := map[string]*cobra.Command{}
-			for _, plugin := range allCommands() {
-				plugins[plugin.Name()] = plugin
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+				store := stores[0]
+				if len(stores) > 1 {
+					store = "<forensicstore>"
+				}
+				return printPlan(cmd.OutOrStdout(), workflow, store)
 			}
 
-			workflow.SetupGraph()
-			return workflow.Run(args[0], plugins)
+			commands := Executors()
+			out, closeEvents, err := setupEvents(cmd, workflow, commands)
+			if err != nil {
+				return err
+			}
+			defer closeEvents()
+			invocation := workflowInvocation(workflowFile, workflow, commands)
+			if len(stores) == 1 {
+				return runStore(cmd, workflow, stores[0], commands, invocation)
+			}
+			return runStores(cmd, out, workflow, stores, commands, invocation)
 		},
 	}
 	workflowCmd.Flags().StringP("file", "f", "", "workflow definition file")
+	workflowCmd.Flags().Int("parallelism", 0, "maximum number of parallel tasks (default number of CPUs)")
+	workflowCmd.Flags().Bool("resume", false, "skip tasks that already succeeded with identical arguments")
+	workflowCmd.Flags().Bool("no-cache", false, "run tasks even if their inputs did not change")
+	workflowCmd.Flags().StringArray("set", nil, "set a workflow variable (key=value)")
+	workflowCmd.Flags().String("workdir", "",
+		"directory for the working directories of runs (default temporary directory)")
+	workflowCmd.Flags().Bool("keep-workdir", false, "keep the working directory with the task outputs")
+	workflowCmd.Flags().StringArray("target", nil, "run only this task and the tasks it requires")
+	workflowCmd.Flags().StringArray("skip", nil, "do not run this task and the tasks that require it")
+	workflowCmd.Flags().Bool("dry-run", false, "print the execution plan without running it")
+	workflowCmd.Flags().String("report", "", "write a JSON report of the run to this file")
+	workflowCmd.Flags().String("events", "", "write task events in this format (jsonl)")
+	workflowCmd.Flags().String("events-file", "-",
+		"file for the task events, - for stdout (task output then goes to stderr)")
+	workflowCmd.Flags().Int("stores", 1, "maximum number of forensicstores processed at the same time")
 	_ = workflowCmd.MarkFlagRequired("file")
+	workflowCmd.AddCommand(validateWorkflow(), graphWorkflow(), workflowSchema())
 	return workflowCmd
 }
+
+// setupWorkflow parses the workflow file and applies the flags of the
+// workflow command.
+func setupWorkflow(cmd *cobra.Command) (string, *daggy.Workflow, error) {
+	// parse workflow yaml
+	workflowFile, _ := cmd.Flags().GetString("file")
+	if _, err := os.Stat(workflowFile); os.IsNotExist(err) {
+		log.Fatal(err, workflowFile)
+	}
+	workflow, err := daggy.ParseWithBuiltins(workflowFile, builtinWorkflows())
+	if err != nil {
+		log.Fatal("parsing failed: ", err)
+	}
+	if cmd.Flags().Changed("parallelism") {
+		workflow.Parallelism, _ = cmd.Flags().GetInt("parallelism")
+	}
+	workflow.Resume, _ = cmd.Flags().GetBool("resume")
+	workflow.NoCache, _ = cmd.Flags().GetBool("no-cache")
+	workflow.WorkDir, _ = cmd.Flags().GetString("workdir")
+	workflow.KeepWorkDir, _ = cmd.Flags().GetBool("keep-workdir")
+
+	sets, _ := cmd.Flags().GetStringArray("set")
+	for _, set := range sets {
+		kv := strings.SplitN(set, "=", 2)
+		if len(kv) != 2 { //nolint: gomnd
+			return "", nil, fmt.Errorf("invalid variable %s, use key=value", set)
+		}
+		if workflow.Vars == nil {
+			workflow.Vars = map[string]string{}
+		}
+		workflow.Vars[kv[0]] = kv[1]
+	}
+
+	workflow.SetupGraph()
+	targets, _ := cmd.Flags().GetStringArray("target")
+	skip, _ := cmd.Flags().GetStringArray("skip")
+	if err := workflow.Select(targets, skip); err != nil {
+		return "", nil, err
+	}
+	return workflowFile, workflow, workflow.Validate()
+}
+
+// setupEvents sets the observer for --events. It returns the writer for the
+// output of the workflow command, which is the standard error if the events
+// go to the standard output.
+func setupEvents(cmd *cobra.Command, workflow *daggy.Workflow,
+	commands map[string]daggy.Executor) (io.Writer, func(), error) {
+	format, _ := cmd.Flags().GetString("events")
+	if format == "" {
+		return cmd.OutOrStdout(), func() {}, nil
+	}
+	if format != "jsonl" {
+		return nil, nil, fmt.Errorf("unknown events format %s, use jsonl", format)
+	}
+	eventsFile, _ := cmd.Flags().GetString("events-file")
+	events, closeEvents, err := openEvents(cmd, eventsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	workflow.Observer = daggy.NewJSONLObserver(events)
+	if eventsFile != "-" {
+		return cmd.OutOrStdout(), closeEvents, nil
+	}
+	// the standard output only contains events
+	redirectOutput(commands, cmd.ErrOrStderr())
+	return cmd.ErrOrStderr(), closeEvents, nil
+}
+
+// runStore runs the workflow on a single forensicstore.
+func runStore(cmd *cobra.Command, workflow *daggy.Workflow, store string, commands map[string]daggy.Executor,
+	invocation provenance) error {
+	report, err := workflow.RunWithReport(cmd.Context(), store, commands)
+	invocation.record(store, report, err)
+	if reportFile, _ := cmd.Flags().GetString("report"); report != nil && reportFile != "" {
+		if writeErr := report.WriteFile(reportFile); writeErr != nil {
+			log.Printf("could not write report: %s", writeErr)
+		}
+	}
+	return err
+}
+
+// runStores runs the workflow on multiple forensicstores and prints a summary
+// to out.
+func runStores(cmd *cobra.Command, out io.Writer, workflow *daggy.Workflow, stores []string,
+	commands map[string]daggy.Executor, invocation provenance) error {
+	inFlight, _ := cmd.Flags().GetInt("stores")
+	results, err := workflow.RunStores(cmd.Context(), stores, commands, inFlight, invocation.record)
+	printStoreResults(out, results)
+	if reportFile, _ := cmd.Flags().GetString("report"); reportFile != "" {
+		if err := writeReports(reportFile, results); err != nil {
+			log.Printf("could not write report: %s", err)
+		}
+	}
+	return err
+}
+
+// validateWorkflow is a subcommand to check a workflow file without running
+// it.
+func validateWorkflow() *cobra.Command {
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check a workflow file",
+		Long: `validate checks that all commands of a workflow exist, that the arguments are flags
+of the commands with values of the right type, that required tasks exist and that
+the tasks do not form a cycle.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			workflowFile, _ := cmd.Flags().GetString("file")
+			problems, err := daggy.Validate(workflowFile, builtinWorkflows(), Executors())
+			if err != nil {
+				return fmt.Errorf("parsing failed: %w", err)
+			}
+			for _, problem := range problems {
+				fmt.Fprintln(cmd.OutOrStdout(), problem)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%s: %d problems found", workflowFile, len(problems))
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: ok\n", workflowFile)
+			return nil
+		},
+	}
+	validateCmd.Flags().StringP("file", "f", "", "workflow definition file")
+	_ = validateCmd.MarkFlagRequired("file")
+	return validateCmd
+}
+
+// builtinWorkflows returns the workflows shipped with forensicworkflows, which
+// can be included by name.
+func builtinWorkflows() map[string][]byte {
+	workflows := map[string][]byte{}
+	for name, data := range assets.FS {
+		if strings.HasPrefix(name, "/workflows/") && strings.HasSuffix(name, ".yml") {
+			workflows[strings.TrimSuffix(path.Base(name), ".yml")] = data
+		}
+	}
+	return workflows
+}
+
+// openEvents opens the file for the event stream, "-" is the standard
+// output.
+func openEvents(cmd *cobra.Command, name string) (io.Writer, func(), error) {
+	if name == "-" {
+		return cmd.OutOrStdout(), func() {}, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create events file: %w", err)
+	}
+	return f, func() {
+		if err := f.Close(); err != nil {
+			log.Printf("could not close events file: %s", err)
+		}
+	}, nil
+}
+
+// redirectOutput makes all commands write their output to w.
+func redirectOutput(executors map[string]daggy.Executor, w io.Writer) {
+	for name, executor := range executors {
+		if command, ok := executor.(commandExecutor); ok {
+			command.out = w
+			executors[name] = command
+		}
+	}
+}
+
+// writeReports writes the reports of all forensicstores as JSON list.
+func writeReports(path string, results []daggy.StoreResult) error {
+	reports := []*daggy.Report{}
+	for _, result := range results {
+		if result.Report != nil {
+			reports = append(reports, result.Report)
+		}
+	}
+	b, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644) // #nosec
+}
+
+func printStoreResults(w io.Writer, results []daggy.StoreResult) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Forensicstore", "Status", "Duration", "Error"})
+	table.SetAutoWrapText(false)
+	for _, result := range results {
+		status, message := "succeeded", ""
+		if result.Err != nil {
+			status, message = "failed", strings.ReplaceAll(result.Err.Error(), "\n", " ")
+			var runErr *daggy.RunError
+			switch {
+			case errors.Is(result.Err, context.Canceled):
+				status = "cancelled"
+			case errors.As(result.Err, &runErr) && runErr.ExitCode() == daggy.ExitOptionalFailures:
+				status = "optional failures"
+			}
+		}
+		table.Append([]string{result.Store, status, result.Duration.Round(time.Second).String(), message})
+	}
+	table.Render()
+}
+
+// graphWorkflow is a subcommand to render the task graph of a workflow.
+func graphWorkflow() *cobra.Command {
+	graphCmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Render the task graph of a workflow",
+		Long: `graph renders the task graph of a workflow as Graphviz DOT or Mermaid flowchart.
+If a forensicstore is given, the tasks are colored by their status in the last run
+of the workflow on this forensicstore.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			workflowFile, _ := cmd.Flags().GetString("file")
+			workflow, err := daggy.ParseWithBuiltins(workflowFile, builtinWorkflows())
+			if err != nil {
+				return fmt.Errorf("parsing failed: %w", err)
+			}
+			workflow.SetupGraph()
+
+			var statuses map[string]daggy.Status
+			if store, _ := cmd.Flags().GetString("store"); store != "" {
+				if _, err := os.Stat(store); err != nil {
+					return err
+				}
+				statuses, err = workflow.LastStatuses(store)
+				if err != nil {
+					return err
+				}
+			}
+
+			format, _ := cmd.Flags().GetString("format")
+			switch format {
+			case "dot":
+				return workflow.WriteDOT(cmd.OutOrStdout(), statuses)
+			case "mermaid":
+				return workflow.WriteMermaid(cmd.OutOrStdout(), statuses)
+			default:
+				return fmt.Errorf("unknown format %s, use dot or mermaid", format)
+			}
+		},
+	}
+	graphCmd.Flags().StringP("file", "f", "", "workflow definition file")
+	graphCmd.Flags().String("format", "dot", "output format (dot or mermaid)")
+	graphCmd.Flags().String("store", "", "color tasks by their status in the last run on this forensicstore")
+	_ = graphCmd.MarkFlagRequired("file")
+	return graphCmd
+}
+
+// workflowSchema is a subcommand to print the JSON Schema of workflow files.
+func workflowSchema() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of workflow files",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprint(cmd.OutOrStdout(), daggy.Schema)
+		},
+	}
+}
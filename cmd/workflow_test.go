@@ -0,0 +1,217 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/forensicanalysis/forensicworkflows/daggy"
+)
+
+func Test_redirectOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redirect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := filepath.Join(dir, "test.forensicstore")
+	insertElement(t, store)
+
+	// the output stands in for the standard error of --events jsonl
+	out, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	commands := BuiltinExecutors()
+	redirectOutput(commands, out)
+	workflow := &daggy.Workflow{Tasks: map[string]daggy.Task{
+		"first":  {Command: "export", Arguments: map[string]interface{}{"format": "jsonl"}},
+		"second": {Command: "export", Arguments: map[string]interface{}{"format": "jsonl"}, Requires: []string{"first"}},
+	}, NoCache: true}
+	workflow.SetupGraph()
+	if err := workflow.Run(context.Background(), store, commands); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := out.WriteString("still open\n"); err != nil {
+		t.Fatalf("output closed by tasks: %s", err)
+	}
+	b, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 3 {
+		t.Errorf("output has %d lines, want an element per task and the last line: %s", lines, b)
+	}
+}
+
+func Test_printStoreResults(t *testing.T) {
+	results := []daggy.StoreResult{
+		{Store: "done.forensicstore"},
+		{Store: "failed.forensicstore", Err: &daggy.RunError{Failed: map[string]string{"a": "broken"}}},
+		{Store: "optional.forensicstore", Err: &daggy.RunError{Allowed: map[string]string{"a": "broken"}}},
+		{Store: "interrupted.forensicstore", Err: &daggy.RunError{Failed: map[string]string{"a": "task cancelled"}, Cancelled: true}},
+		{Store: "waiting.forensicstore", Err: context.Canceled},
+	}
+	want := map[string]string{
+		"done.forensicstore":        "succeeded",
+		"failed.forensicstore":      "failed",
+		"optional.forensicstore":    "optional failures",
+		"interrupted.forensicstore": "cancelled",
+		"waiting.forensicstore":     "cancelled",
+	}
+
+	b := &strings.Builder{}
+	printStoreResults(b, results)
+	for _, line := range strings.Split(b.String(), "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 3 {
+			continue
+		}
+		store, status := strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2])
+		if wantStatus, ok := want[store]; ok && status != wantStatus {
+			t.Errorf("printStoreResults() %s = %s, want %s", store, status, wantStatus)
+		}
+		delete(want, store)
+	}
+	if len(want) > 0 {
+		t.Errorf("printStoreResults() missing %v", want)
+	}
+}
+
+func TestWorkflow_flags(t *testing.T) {
+	exported := func(dir string, files ...string) []string {
+		var got []string
+		for _, file := range files {
+			if exists(filepath.Join(dir, file)) {
+				got = append(got, file)
+			}
+		}
+		return got
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+		check   func(t *testing.T, dir, out string)
+	}{
+		{"all tasks", nil, []string{"default-first.jsonl", "default-second.jsonl"}, false, nil},
+		{"set", []string{"--set", "prefix=set-"}, []string{"set-first.jsonl", "set-second.jsonl"}, false, nil},
+		{"invalid set", []string{"--set", "prefix"}, nil, true, nil},
+		{"target", []string{"--target", "first"}, []string{"default-first.jsonl"}, false, nil},
+		{"skip", []string{"--skip", "first"}, nil, false, nil},
+		{"unknown target", []string{"--target", "third"}, nil, true, nil},
+		{"dry run", []string{"--dry-run"}, nil, false, func(t *testing.T, dir, out string) {
+			if !strings.Contains(out, "wave 2:") || !strings.Contains(out, "requires: first") {
+				t.Errorf("Execute() plan = %q, want two waves", out)
+			}
+		}},
+		{"events", []string{"--events", "jsonl", "--events-file", "events.jsonl"},
+			[]string{"default-first.jsonl", "default-second.jsonl"}, false, func(t *testing.T, dir, out string) {
+				b, err := ioutil.ReadFile(filepath.Join(dir, "events.jsonl"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if finished := strings.Count(string(b), `"type":"task_finished"`); finished != 2 {
+					t.Errorf("Execute() events have %d finished tasks, want 2: %s", finished, b)
+				}
+			}},
+		{"unknown events format", []string{"--events", "xml"}, nil, true, nil},
+		{"report", []string{"--report", "report.json"},
+			[]string{"default-first.jsonl", "default-second.jsonl"}, false, func(t *testing.T, dir, out string) {
+				b, err := ioutil.ReadFile(filepath.Join(dir, "report.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				report := daggy.Report{}
+				if err := json.Unmarshal(b, &report); err != nil {
+					t.Fatal(err)
+				}
+				if report.Status != daggy.StatusSucceeded || len(report.Tasks) != 2 {
+					t.Errorf("Execute() report = %s, want two succeeded tasks", b)
+				}
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "workflowflags")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			store := filepath.Join(dir, "test.forensicstore")
+			insertElement(t, store)
+			workflowFile := filepath.Join(dir, "workflow.yml")
+			err = ioutil.WriteFile(workflowFile, []byte(`
+vars:
+  dir: `+dir+`
+  prefix: default-
+tasks:
+  first:
+    command: export
+    arguments: {format: jsonl, output: "${dir}/${prefix}first.jsonl"}
+  second:
+    command: export
+    requires: [first]
+    arguments: {format: jsonl, output: "${dir}/${prefix}second.jsonl"}
+`), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var args []string
+			for _, arg := range tt.args {
+				if strings.HasSuffix(arg, ".json") || strings.HasSuffix(arg, ".jsonl") {
+					arg = filepath.Join(dir, arg)
+				}
+				args = append(args, arg)
+			}
+			out := &strings.Builder{}
+			command := Workflow()
+			command.SetArgs(append([]string{"--file", workflowFile, "--no-cache", store}, args...))
+			command.SetOut(out)
+			command.SilenceErrors, command.SilenceUsage = true, true
+			if err := command.Execute(); (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got := exported(dir, "default-first.jsonl", "default-second.jsonl", "set-first.jsonl", "set-second.jsonl")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() exported %v, want %v", got, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t, dir, out.String())
+			}
+		})
+	}
+}
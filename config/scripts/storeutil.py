@@ -20,6 +20,7 @@
 # Author(s): Jonas Plum
 
 import argparse
+import json
 
 
 def merge_conditions(list_a, list_b):
@@ -38,10 +39,17 @@ class DictListAction(argparse.Action):
     # pylint: disable=too-few-public-methods
 
     def __call__(self, parser, namespace, values, option_string=None):
-        flag = {}
-        for kv in values.split(","):
-            key, value = kv.split("=")
-            flag[key] = value
+        # workflows pass objects as JSON, e.g. {"type": "file"}
+        if values.startswith("{"):
+            try:
+                flag = {key: str(value) for key, value in json.loads(values).items()}
+            except (ValueError, AttributeError) as error:
+                parser.error("invalid %s: %s" % (option_string, error))
+        else:
+            flag = {}
+            for kv in values.split(","):
+                key, value = kv.split("=")
+                flag[key] = value
         if hasattr(namespace, self.dest):
             flags = getattr(namespace, self.dest)
             if flags is not None:
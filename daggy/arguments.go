@@ -0,0 +1,103 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+)
+
+type argumentsKey struct{}
+
+// WithArguments returns a context that carries the typed arguments of a task,
+// so plugins do not depend on the command line representation.
+func WithArguments(ctx context.Context, arguments map[string]interface{}) context.Context {
+	normalized, _ := normalize(arguments).(map[string]interface{})
+	if normalized == nil {
+		normalized = map[string]interface{}{}
+	}
+	return context.WithValue(ctx, argumentsKey{}, normalized)
+}
+
+// Arguments returns the typed arguments of the task that runs with the
+// context. Nested maps have string keys, so the arguments can be encoded as
+// JSON.
+func Arguments(ctx context.Context) (map[string]interface{}, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	arguments, ok := ctx.Value(argumentsKey{}).(map[string]interface{})
+	return arguments, ok
+}
+
+// ToFilter converts a typed filter argument, a list of conditions or a single
+// condition, into a Filter.
+func ToFilter(value interface{}) (Filter, error) {
+	switch v := normalize(value).(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return ToFilter([]interface{}{v})
+	case []interface{}:
+		filter := Filter{}
+		for _, item := range v {
+			condition, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("filter condition must be a map, got %T", item)
+			}
+			values := map[string]string{}
+			for attribute, value := range condition {
+				values[attribute] = fmt.Sprint(value)
+			}
+			filter = append(filter, values)
+		}
+		return filter, nil
+	default:
+		return nil, fmt.Errorf("filter must be a list of maps, got %T", value)
+	}
+}
+
+// normalize converts maps parsed from yaml into maps with string keys.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := map[string]interface{}{}
+		for key, item := range v {
+			normalized[fmt.Sprint(key)] = normalize(item)
+		}
+		return normalized
+	case map[string]interface{}:
+		normalized := map[string]interface{}{}
+		for key, item := range v {
+			normalized[key] = normalize(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	default:
+		return value
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestToFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    Filter
+		wantErr bool
+	}{
+		{"nil", nil, nil, false},
+		{"list", []interface{}{
+			map[interface{}]interface{}{"type": "file", "name": "a,b.txt"},
+			map[string]interface{}{"size": 3},
+		}, Filter{{"type": "file", "name": "a,b.txt"}, {"size": "3"}}, false},
+		{"single condition", map[interface{}]interface{}{"type": "file"}, Filter{{"type": "file"}}, false},
+		{"string", "type=file", nil, true},
+		{"list of strings", []interface{}{"type=file"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToFilter(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToFilter() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithArguments(t *testing.T) {
+	ctx := WithArguments(context.Background(), map[string]interface{}{
+		"filter": []interface{}{map[interface{}]interface{}{"name": "a,b=c.txt"}},
+		"limit":  3,
+	})
+	got, ok := Arguments(ctx)
+	if !ok {
+		t.Fatal("Arguments() found no arguments")
+	}
+
+	want := map[string]interface{}{
+		"filter": []interface{}{map[string]interface{}{"name": "a,b=c.txt"}},
+		"limit":  3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Arguments() = %#v, want %#v", got, want)
+	}
+}
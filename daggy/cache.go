@@ -0,0 +1,253 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"crawshaw.io/sqlite"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+// cacheTable stores the fingerprints of successful tasks. The underscore
+// prefix hides the table from the forensicstore element types.
+const cacheTable = "_workflow_cache"
+
+// cacheable reports whether a task may be skipped if its inputs are
+// unchanged. Tasks without an input filter read the whole store, which
+// changes with every task, and tasks that write files or print their results
+// must always run to produce them.
+func cacheable(info CommandInfo, task Task) bool {
+	if inputFilter(info, task) == nil || len(task.Outputs) > 0 {
+		return false
+	}
+	if _, ok := task.Arguments["output"]; ok {
+		return false
+	}
+	if info.Flags != nil && info.Flags.Lookup("format") != nil {
+		format, ok := task.Arguments["format"]
+		return ok && fmt.Sprint(format) == "none"
+	}
+	return true
+}
+
+// fingerprint identifies the inputs of a task: the command, its arguments,
+// the command version and the elements the task reads from the forensicstore.
+func (r *run) fingerprint(info CommandInfo, task Task) (string, error) {
+	r.limits.store.RLock()
+	defer r.limits.store.RUnlock()
+
+	store, teardown, err := forensicstore.Open(r.storeDir)
+	if err != nil {
+		return "", err
+	}
+	defer teardown()
+
+	h := sha256.New()
+	fmt.Fprintln(h, task.Command)
+	fmt.Fprintln(h, strings.Join(task.cmdline(), " "))
+	fmt.Fprintln(h, info.Version)
+	if err := digestElements(store.Connection(), inputFilter(info, task), h); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// digestElements writes the elements selected by the filter to w without
+// loading them into memory. Records of runs and invocations are no inputs.
+func digestElements(conn *sqlite.Conn, filter Filter, w io.Writer) error {
+	params := map[string]string{}
+	var ors []string
+	for _, condition := range filter {
+		var ands []string
+		for key, value := range condition {
+			i := len(params) / 2
+			params[fmt.Sprintf("$key%d", i)] = "$." + key
+			params[fmt.Sprintf("$value%d", i)] = value
+			ands = append(ands, fmt.Sprintf("json_extract(json, $key%d) LIKE $value%d", i, i))
+		}
+		if len(ands) > 0 {
+			ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+		}
+	}
+
+	query := "SELECT json FROM \"elements\" WHERE json_extract(json, '$.type') NOT LIKE 'workflow\\_%' ESCAPE '\\' " +
+		"AND json_extract(json, '$.type') != 'provenance'"
+	if len(ors) > 0 {
+		query += " AND (" + strings.Join(ors, " OR ") + ")"
+	}
+
+	stmt, err := conn.Prepare(query) // #nosec
+	if err != nil {
+		return err
+	}
+	for param, value := range params {
+		stmt.SetText(param, value)
+	}
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			stmt.Finalize() // nolint: errcheck
+			return err
+		}
+		if !hasRow {
+			break
+		}
+		fmt.Fprintln(w, stmt.ColumnText(0))
+	}
+	return stmt.Finalize()
+}
+
+// inputFilter returns the filter argument of the task, or the default input
+// filter of the command, e.g. "type=file,name=%.evtx". Filter arguments are
+// either typed conditions or filtersets. Filter arguments that cannot be
+// read return nil, so the task is not cached.
+func inputFilter(info CommandInfo, task Task) Filter {
+	filter, ok := task.Arguments["filter"]
+	if !ok {
+		return info.InputFilter
+	}
+	if filtersets, ok := toFiltersets(filter); ok {
+		return ParseFilter(filtersets...)
+	}
+	f, err := ToFilter(filter)
+	if err != nil || len(f) == 0 {
+		return nil
+	}
+	return f
+}
+
+// toFiltersets returns the filtersets of a filter argument that is a string
+// or a list of strings.
+func toFiltersets(filter interface{}) ([]string, bool) {
+	switch v := filter.(type) {
+	case string:
+		return []string{v}, true
+	case []string:
+		return v, true
+	case []interface{}:
+		var filtersets []string
+		for _, item := range v {
+			filterset, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			filtersets = append(filtersets, filterset)
+		}
+		return filtersets, len(filtersets) > 0
+	}
+	return nil, false
+}
+
+func parseFilterset(filterset string) map[string]string {
+	condition := map[string]string{}
+	for _, kv := range strings.Split(filterset, ",") {
+		kvl := strings.SplitN(kv, "=", 2)
+		if len(kvl) == 2 { //nolint: gomnd
+			condition[kvl[0]] = kvl[1]
+		}
+	}
+	return condition
+}
+
+// cached checks if the fingerprint was recorded by a previous run.
+func (r *run) cached(fingerprint string) bool {
+	r.limits.store.RLock()
+	defer r.limits.store.RUnlock()
+
+	store, teardown, err := forensicstore.Open(r.storeDir)
+	if err != nil {
+		return false
+	}
+	defer teardown()
+
+	tables, err := queryInt(store.Connection(), "SELECT count(*) FROM sqlite_master WHERE name = $table",
+		map[string]string{"$table": cacheTable})
+	if err != nil || tables == 0 {
+		return false
+	}
+
+	fingerprints, err := queryInt(store.Connection(),
+		"SELECT count(*) FROM \""+cacheTable+"\" WHERE fingerprint = $fingerprint",
+		map[string]string{"$fingerprint": fingerprint})
+	if err != nil {
+		log.Printf("could not read cache: %s", err)
+		return false
+	}
+	return fingerprints > 0
+}
+
+// cache records the fingerprint of a successful task.
+func (r *run) cache(fingerprint, name string) {
+	r.limits.store.Lock()
+	defer r.limits.store.Unlock()
+
+	store, teardown, err := forensicstore.Open(r.storeDir)
+	if err != nil {
+		log.Printf("could not write cache: %s", err)
+		return
+	}
+	defer teardown()
+
+	_, err = queryInt(store.Connection(), "CREATE TABLE IF NOT EXISTS \""+cacheTable+"\" "+
+		"(fingerprint TEXT NOT NULL PRIMARY KEY, task TEXT, insert_time TEXT)", nil)
+	if err != nil {
+		log.Printf("could not write cache: %s", err)
+		return
+	}
+	_, err = queryInt(store.Connection(), "INSERT OR REPLACE INTO \""+cacheTable+"\" (fingerprint, task, insert_time) "+
+		"VALUES ($fingerprint, $task, $time)", map[string]string{
+		"$fingerprint": fingerprint,
+		"$task":        name,
+		"$time":        time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		log.Printf("could not write cache: %s", err)
+	}
+}
+
+// queryInt executes a query and returns the integer in the first column of the
+// first row, if any.
+func queryInt(conn *sqlite.Conn, query string, params map[string]string) (int64, error) {
+	stmt, err := conn.Prepare(query) // #nosec
+	if err != nil {
+		return 0, err
+	}
+	for param, value := range params {
+		stmt.SetText(param, value)
+	}
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return 0, err
+	}
+	var i int64
+	if hasRow && stmt.ColumnCount() > 0 {
+		i = stmt.ColumnInt64(0)
+	}
+	return i, stmt.Finalize()
+}
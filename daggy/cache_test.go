@@ -0,0 +1,154 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+func TestWorkflow_RunCache(t *testing.T) {
+	storeDir, cleanupStore := newTestStore(t, `{"type": "test", "name": "a.evtx"}`)
+	defer cleanupStore()
+
+	runs := 0
+	example := testCommand{
+		run: func(ctx context.Context, store string, spec TaskSpec) error {
+			runs++
+			return nil
+		},
+		info: CommandInfo{Version: "v1", InputFilter: Filter{{"name": "%.evtx"}}},
+	}
+
+	workflow := &Workflow{Tasks: map[string]Task{"eventlogs": {Command: "example"}}}
+	workflow.SetupGraph()
+
+	insert := func(element string) {
+		store, teardown, err := forensicstore.Open(storeDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer teardown()
+		if _, err := store.Insert(forensicstore.JSONElement(element)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name     string
+		prepare  func()
+		wantRuns int
+	}{
+		{"first run", func() {}, 1},
+		{"unchanged", func() {}, 1},
+		{"unrelated element", func() { insert(`{"type": "test", "name": "a.pf"}`) }, 1},
+		{"new input element", func() { insert(`{"type": "test", "name": "b.evtx"}`) }, 2},
+		{"provenance element", func() { insert(`{"type": "provenance", "name": "c.evtx"}`) }, 2},
+		{"new plugin version", func() { example.info.Version = "v2" }, 3},
+		{"output file", func() {
+			workflow.Tasks["eventlogs"] = Task{Command: "example", Arguments: map[string]interface{}{"output": "out.txt"}}
+		}, 4},
+		{"output file again", func() {}, 5},
+		{"no cache", func() { workflow.NoCache = true }, 6},
+	}
+	for _, step := range steps {
+		step.prepare()
+		if err := workflow.Run(context.Background(), storeDir, executors(map[string]testCommand{"example": example})); err != nil {
+			t.Fatal(err)
+		}
+		if runs != step.wantRuns {
+			t.Errorf("%s: runs = %d, want %d", step.name, runs, step.wantRuns)
+		}
+	}
+}
+
+func Test_inputFilter(t *testing.T) {
+	filterArgument := []interface{}{
+		map[interface{}]interface{}{"type": "file", "name": "%.evtx"},
+		map[interface{}]interface{}{"type": "registry"},
+	}
+
+	type args struct {
+		info CommandInfo
+		task Task
+	}
+	tests := []struct {
+		name string
+		args args
+		want Filter
+	}{
+		{"no filter", args{CommandInfo{}, Task{}}, nil},
+		{"task filter", args{CommandInfo{}, Task{Arguments: map[string]interface{}{"filter": filterArgument}}},
+			Filter{{"type": "file", "name": "%.evtx"}, {"type": "registry"}}},
+		{"comma in value", args{CommandInfo{}, Task{Arguments: map[string]interface{}{"filter": []interface{}{
+			map[interface{}]interface{}{"name": "a,b.txt"},
+		}}}}, Filter{{"name": "a,b.txt"}}},
+		{"filtersets", args{CommandInfo{}, Task{Arguments: map[string]interface{}{"filter": []interface{}{"type=file,name=%.evtx", "type=registry"}}}},
+			Filter{{"type": "file", "name": "%.evtx"}, {"type": "registry"}}},
+		{"invalid filter", args{CommandInfo{}, Task{Arguments: map[string]interface{}{"filter": 1}}}, nil},
+		{"plugin filter", args{CommandInfo{InputFilter: Filter{{"type": "file", "name": "%.pf"}}}, Task{}},
+			Filter{{"type": "file", "name": "%.pf"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inputFilter(tt.args.info, tt.args.task); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inputFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_cacheable(t *testing.T) {
+	filter := Filter{{"name": "%.evtx"}}
+	outputFlags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+	outputFlags.String("format", "table", "")
+
+	tests := []struct {
+		name string
+		info CommandInfo
+		task Task
+		want bool
+	}{
+		{"input filter", CommandInfo{InputFilter: filter}, Task{}, true},
+		{"no input filter", CommandInfo{}, Task{}, false},
+		{"filter argument", CommandInfo{}, Task{Arguments: map[string]interface{}{"filter": []interface{}{
+			map[interface{}]interface{}{"type": "file"},
+		}}}, true},
+		{"output argument", CommandInfo{InputFilter: filter}, Task{Arguments: map[string]interface{}{"output": "a.txt"}}, false},
+		{"declared outputs", CommandInfo{InputFilter: filter}, Task{Outputs: map[string]string{"a": "a.txt"}}, false},
+		{"printed output", CommandInfo{InputFilter: filter, Flags: outputFlags}, Task{}, false},
+		{"no printed output", CommandInfo{InputFilter: filter, Flags: outputFlags},
+			Task{Arguments: map[string]interface{}{"format": "none"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheable(tt.info, tt.task); got != tt.want {
+				t.Errorf("cacheable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+// A Condition decides if a task is run, based on the number of elements in
+// the forensicstore that match the filter.
+type Condition struct {
+	Filter Filter `yaml:"filter"`
+	// Count compares the number of matching elements with a number, e.g.
+	// ">= 1" or "== 0". Defaults to ">= 1", at least one matching element.
+	Count string `yaml:"count"`
+}
+
+// Check evaluates the condition against the forensicstore.
+func (c *Condition) Check(storeDir string) (bool, error) {
+	store, teardown, err := forensicstore.Open(storeDir)
+	if err != nil {
+		return false, err
+	}
+	defer teardown()
+
+	elements, err := c.Filter.Select(store)
+	if err != nil {
+		return false, err
+	}
+	return c.compare(len(elements))
+}
+
+func (c *Condition) compare(count int) (bool, error) {
+	expression := strings.TrimSpace(c.Count)
+	if expression == "" {
+		expression = ">= 1"
+	}
+
+	operator := strings.TrimRight(expression, " 0123456789")
+	number, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(expression, operator)))
+	if err != nil {
+		return false, fmt.Errorf("invalid count %q", c.Count)
+	}
+
+	switch strings.TrimSpace(operator) {
+	case ">=":
+		return count >= number, nil
+	case ">":
+		return count > number, nil
+	case "<=":
+		return count <= number, nil
+	case "<":
+		return count < number, nil
+	case "==", "":
+		return count == number, nil
+	case "!=":
+		return count != number, nil
+	default:
+		return false, fmt.Errorf("invalid count %q", c.Count)
+	}
+}
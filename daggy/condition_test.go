@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+func newTestStore(t *testing.T, elements ...string) (storeDir string, cleanupStore func()) {
+	tempDir, err := ioutil.TempDir("", "forensicstoreconditiontest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeDir = filepath.Join(tempDir, "test.forensicstore")
+	store, teardown, err := forensicstore.New(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, element := range elements {
+		if _, err := store.Insert(forensicstore.JSONElement(element)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+	return storeDir, func() { cleanup(tempDir) } // nolint: errcheck
+}
+
+func TestCondition_Check(t *testing.T) {
+	storeDir, cleanupStore := newTestStore(t,
+		`{"type": "test", "name": "a.evtx"}`,
+		`{"type": "test", "name": "b.evtx"}`,
+		`{"type": "test", "name": "c.pf"}`,
+		`{"type": "workflow_task", "name": "d.evtx"}`,
+	)
+	defer cleanupStore()
+
+	tests := []struct {
+		name      string
+		condition Condition
+		want      bool
+		wantErr   bool
+	}{
+		{"default count", Condition{Filter: Filter{{"name": "%.evtx"}}}, true, false},
+		{"no match", Condition{Filter: Filter{{"name": "%.lnk"}}}, false, false},
+		{"no match expected", Condition{Filter: Filter{{"name": "%.lnk"}}, Count: "== 0"}, true, false},
+		{"greater", Condition{Filter: Filter{{"name": "%.evtx"}}, Count: "> 2"}, false, false},
+		{"less", Condition{Filter: Filter{{"type": "test"}}, Count: "<3"}, false, false},
+		{"not equal", Condition{Filter: Filter{{"type": "test"}}, Count: "!= 2"}, true, false},
+		{"plain number", Condition{Filter: Filter{{"name": "c.pf"}}, Count: "1"}, true, false},
+		{"without engine elements", Condition{Filter: Filter{{"name": "%.evtx"}}, Count: "== 2"}, true, false},
+		{"engine elements", Condition{Filter: Filter{{"type": "workflow_task"}}, Count: "== 1"}, true, false},
+		{"invalid count", Condition{Count: "=> 1"}, false, true},
+		{"missing number", Condition{Count: ">="}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.condition.Check(storeDir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Check() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunWhen(t *testing.T) {
+	storeDir, cleanupStore := newTestStore(t, `{"type": "test", "name": "a.pf"}`)
+	defer cleanupStore()
+
+	var ran []string
+	plugins := map[string]testCommand{"example": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+		ran = append(ran, store)
+		return nil
+	}}}
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"evtx":        {Command: "example", When: &Condition{Filter: Filter{{"name": "%.evtx"}}}},
+		"evtx_report": {Command: "example", Requires: []string{"evtx"}},
+		"prefetch":    {Command: "example", When: &Condition{Filter: Filter{{"name": "%.pf"}}}},
+	}, Parallelism: 1}
+	workflow.SetupGraph()
+
+	if err := workflow.Run(context.Background(), storeDir, executors(plugins)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ran, []string{storeDir}) {
+		t.Errorf("Run() ran %v, want only prefetch", ran)
+	}
+}
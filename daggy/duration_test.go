@@ -0,0 +1,72 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestDuration_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"seconds", "timeout: 90s", 90 * time.Second, false},
+		{"combined", "timeout: 1h30m", 90 * time.Minute, false},
+		{"integer", "timeout: 10", 0, true},
+		{"zero", "timeout: 0", 0, false},
+		{"negative", "timeout: -5s", 0, true},
+		{"invalid", "timeout: soon", 0, true},
+		{"list", "timeout: [10s]", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := Task{}
+			err := yaml.UnmarshalStrict([]byte(tt.yaml), &task)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if time.Duration(task.Timeout) != tt.want {
+				t.Errorf("UnmarshalYAML() = %s, want %s", task.Timeout, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuration_MarshalYAML(t *testing.T) {
+	b, err := yaml.Marshal(Task{Command: "example", Timeout: Duration(90 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := Task{}
+	if err := yaml.UnmarshalStrict(b, &task); err != nil {
+		t.Fatalf("could not read marshalled task %s: %s", b, err)
+	}
+	if time.Duration(task.Timeout) != 90*time.Second {
+		t.Errorf("MarshalYAML() = %s", b)
+	}
+}
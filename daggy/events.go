@@ -0,0 +1,211 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of an event of a workflow run.
+type EventType string
+
+// Event types.
+const (
+	// TaskQueued is emitted when all requirements of a task are done and it
+	// waits to be started.
+	TaskQueued EventType = "task_queued"
+	// TaskStarted is emitted for every attempt of a task.
+	TaskStarted EventType = "task_started"
+	// TaskLog is emitted for log messages of a running task.
+	TaskLog EventType = "task_log"
+	// TaskProgress is emitted when a running task reports progress.
+	TaskProgress EventType = "task_progress"
+	// TaskFinished is emitted with the final status of a task.
+	TaskFinished EventType = "task_finished"
+	// WorkflowFinished is emitted with the result of the run.
+	WorkflowFinished EventType = "workflow_finished"
+)
+
+// An Event describes a change in a workflow run.
+type Event struct {
+	Type  EventType `json:"type"`
+	Time  time.Time `json:"time"`
+	Run   string    `json:"run"`
+	Store string    `json:"store"`
+	Task  string    `json:"task,omitempty"`
+	// Parent is the foreach task of sub-tasks.
+	Parent   string    `json:"parent,omitempty"`
+	Attempt  int       `json:"attempt,omitempty"`
+	Status   Status    `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+}
+
+// Progress is the number of processed items of a task.
+type Progress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// An Observer receives the events of workflow runs. Events are emitted by
+// all running tasks, so observers must be safe for concurrent use.
+type Observer interface {
+	Notify(event Event)
+}
+
+// ObserverFunc is a function that can be used as Observer.
+type ObserverFunc func(event Event)
+
+// Notify calls f.
+func (f ObserverFunc) Notify(event Event) {
+	f(event)
+}
+
+// NewJSONLObserver returns an observer that writes every event as a line of
+// JSON.
+func NewJSONLObserver(w io.Writer) Observer {
+	var mux sync.Mutex
+	return ObserverFunc(func(event Event) {
+		b, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("could not encode event: %s", err)
+			return
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			log.Printf("could not write event: %s", err)
+		}
+	})
+}
+
+// emit passes an event of the run to the observer of the workflow.
+func (r *run) emit(event Event) {
+	if r.workflow.Observer == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	event.Run = r.id
+	event.Store = r.storeDir
+	r.workflow.Observer.Notify(event)
+}
+
+type emitKey struct{}
+
+// withTask returns a context for executors, that emits the log and progress
+// events of the task.
+func (r *run) withTask(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, emitKey{}, func(event Event) {
+		event.Task = name
+		r.emit(event)
+	})
+}
+
+func emitter(ctx context.Context) (func(Event), bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	emit, ok := ctx.Value(emitKey{}).(func(Event))
+	return emit, ok
+}
+
+// Logf emits a TaskLog event for the task that runs with the context. The
+// message is written to the log as well.
+func Logf(ctx context.Context, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	log.Print(message)
+	if emit, ok := emitter(ctx); ok {
+		emit(Event{Type: TaskLog, Message: message})
+	}
+}
+
+// ReportProgress emits a TaskProgress event for the task that runs with the
+// context.
+func ReportProgress(ctx context.Context, done, total int64) {
+	if emit, ok := emitter(ctx); ok {
+		emit(Event{Type: TaskProgress, Progress: &Progress{Done: done, Total: total}})
+	}
+}
+
+// LogWriter returns a writer that emits a TaskLog event for every line
+// written, e.g. the standard error of a script. Everything is written to the
+// log as well. Close emits the last line if it has no trailing newline, it
+// must be called when the task ends.
+func LogWriter(ctx context.Context) io.WriteCloser {
+	return &logWriter{ctx: ctx}
+}
+
+type logWriter struct {
+	ctx    context.Context
+	buffer bytes.Buffer
+	mux    sync.Mutex
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if _, err := log.Writer().Write(p); err != nil {
+		return 0, err
+	}
+	emit, ok := emitter(w.ctx)
+	if !ok {
+		return len(p), nil
+	}
+
+	w.buffer.Write(p)
+	for {
+		line, err := w.buffer.ReadString('\n')
+		if err != nil {
+			// keep the incomplete line for the next write
+			w.buffer.WriteString(line)
+			break
+		}
+		if message := strings.TrimRight(line, "\r\n"); message != "" {
+			emit(Event{Type: TaskLog, Message: message})
+		}
+	}
+	return len(p), nil
+}
+
+// Close emits the incomplete last line.
+func (w *logWriter) Close() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if emit, ok := emitter(w.ctx); ok {
+		if message := strings.TrimRight(w.buffer.String(), "\r\n"); message != "" {
+			emit(Event{Type: TaskLog, Message: message})
+		}
+	}
+	w.buffer.Reset()
+	return nil
+}
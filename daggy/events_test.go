@@ -0,0 +1,108 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWorkflow_RunEvents(t *testing.T) {
+	executor := ExecutorFunc(func(ctx context.Context, store string, spec TaskSpec) (Result, error) {
+		if spec.Name == "fail" {
+			return Result{}, errors.New("broken")
+		}
+		Logf(ctx, "processing %s", spec.Name)
+		ReportProgress(ctx, 1, 2)
+		w := LogWriter(ctx)
+		fmt.Fprint(w, "first line\nsecond")
+		if err := w.Close(); err != nil {
+			return Result{}, err
+		}
+		return Result{}, nil
+	})
+
+	var mux sync.Mutex
+	events := map[string][]string{}
+	observer := ObserverFunc(func(event Event) {
+		mux.Lock()
+		defer mux.Unlock()
+		if event.Run == "" || event.Store != "test.forensicstore" || event.Time.IsZero() {
+			t.Errorf("Notify() incomplete event %+v", event)
+		}
+		description := string(event.Type)
+		switch {
+		case event.Status != "":
+			description += " " + string(event.Status)
+		case event.Message != "":
+			description += " " + event.Message
+		case event.Progress != nil:
+			description += fmt.Sprintf(" %d/%d", event.Progress.Done, event.Progress.Total)
+		}
+		events[event.Task] = append(events[event.Task], description)
+	})
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"a":    {Command: "custom"},
+		"b":    {Command: "custom", Requires: []string{"fail"}},
+		"fail": {Command: "custom", AllowFailure: true},
+	}, NoCache: true, Observer: observer}
+	workflow.SetupGraph()
+
+	_ = workflow.Run(context.Background(), "test.forensicstore", map[string]Executor{"custom": executor})
+
+	want := map[string][]string{
+		"a":    {"task_queued", "task_started", "task_log processing a", "task_progress 1/2", "task_log first line", "task_log second", "task_finished succeeded"},
+		"b":    {"task_queued", "task_finished skipped"},
+		"fail": {"task_queued", "task_started", "task_finished failed"},
+		"":     {"workflow_finished succeeded"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Run() events = %q, want %q", events, want)
+	}
+}
+
+func TestNewJSONLObserver(t *testing.T) {
+	buf := &bytes.Buffer{}
+	observer := NewJSONLObserver(buf)
+	observer.Notify(Event{Type: TaskQueued, Task: "a"})
+	observer.Notify(Event{Type: TaskProgress, Task: "a", Progress: &Progress{Done: 0, Total: 3}})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("NewJSONLObserver() wrote %d lines, want 2", len(lines))
+	}
+	event := Event{}
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != TaskProgress || event.Progress == nil || event.Progress.Total != 3 {
+		t.Errorf("NewJSONLObserver() wrote %s", lines[1])
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+
+	"github.com/spf13/pflag"
+)
+
+// A TaskSpec describes a single execution of a task.
+type TaskSpec struct {
+	// Name of the task, sub-tasks of foreach tasks are named task[item].
+	Name    string
+	Command string
+	// Arguments are the task arguments with all references resolved.
+	Arguments map[string]interface{}
+}
+
+// CommandLine returns the arguments as command line flags, sorted by flag
+// name.
+func (spec TaskSpec) CommandLine() []string {
+	return Task{Arguments: spec.Arguments}.cmdline()
+}
+
+// A Result describes a completed execution of a task.
+type Result struct {
+	// ExitCode is the exit code of commands that run as separate process.
+	ExitCode int
+}
+
+// An Executor runs the tasks of a command. Executors are shared by all
+// tasks and workflow runs and must be safe for concurrent use.
+type Executor interface {
+	Execute(ctx context.Context, store string, spec TaskSpec) (Result, error)
+}
+
+// ExecutorFunc is a function that can be used as Executor.
+type ExecutorFunc func(ctx context.Context, store string, spec TaskSpec) (Result, error)
+
+// Execute calls f.
+func (f ExecutorFunc) Execute(ctx context.Context, store string, spec TaskSpec) (Result, error) {
+	return f(ctx, store, spec)
+}
+
+// CommandInfo describes the command of an executor.
+type CommandInfo struct {
+	// Version of the command, a new version invalidates cached tasks.
+	Version string
+	// Source describes the implementation, e.g. "go built-in".
+	Source string
+	// WritesStore is set for commands that insert elements into the
+	// forensicstore, they never run in parallel to other tasks.
+	WritesStore bool
+	// InputFilter selects the elements the command reads if the task has
+	// no filter argument.
+	InputFilter Filter
+	// Flags are the arguments accepted by the command. Arguments are not
+	// validated if Flags is nil.
+	Flags *pflag.FlagSet
+}
+
+// A Describer is an Executor that describes its command. Executors that do
+// not implement it are assumed to only read from the forensicstore.
+type Describer interface {
+	Info() CommandInfo
+}
+
+func describe(executor Executor) CommandInfo {
+	if describer, ok := executor.(Describer); ok {
+		return describer.Info()
+	}
+	return CommandInfo{}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestTaskSpec_CommandLine(t *testing.T) {
+	spec := TaskSpec{Arguments: map[string]interface{}{"limit": 3, "filter": []interface{}{"type=file", "type=process"}}}
+	want := []string{"--filter", "type=file", "--filter", "type=process", "--limit", "3"}
+	if got := spec.CommandLine(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandLine() = %v, want %v", got, want)
+	}
+}
+
+func TestWorkflow_RunExecutorFunc(t *testing.T) {
+	var mux sync.Mutex
+	var specs []TaskSpec
+	executor := ExecutorFunc(func(ctx context.Context, store string, spec TaskSpec) (Result, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		specs = append(specs, spec)
+		return Result{}, nil
+	})
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"a": {Command: "custom", Arguments: map[string]interface{}{"limit": 3}},
+		"b": {Command: "custom", Foreach: &Foreach{Items: []string{"x"}}, Arguments: map[string]interface{}{"item": "${item}"}},
+	}, NoCache: true}
+	workflow.SetupGraph()
+
+	if err := workflow.Run(context.Background(), "test.forensicstore", map[string]Executor{"custom": executor}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	want := []TaskSpec{
+		{Name: "a", Command: "custom", Arguments: map[string]interface{}{"limit": 3}},
+		{Name: "b[x]", Command: "custom", Arguments: map[string]interface{}{"item": "x"}},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("Run() specs = %+v, want %+v", specs, want)
+	}
+}
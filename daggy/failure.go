@@ -0,0 +1,149 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Failure policies of a workflow.
+const (
+	// OnFailureContinue runs all tasks that do not depend on failed tasks.
+	OnFailureContinue = "continue"
+	// OnFailureStop cancels all running tasks and does not start any new
+	// tasks after a task failed.
+	OnFailureStop = "stop"
+)
+
+// Failure is the on_failure section of a workflow, either the failure
+// policy, the names of the hooks that run if a required task failed, or a
+// mapping of both:
+//
+//	on_failure: stop
+//	on_failure: [notify]
+//	on_failure: {policy: stop, hooks: [notify]}
+type Failure struct {
+	// Policy is either OnFailureContinue (default) or OnFailureStop.
+	Policy string `yaml:"policy"`
+	// Hooks are the names of the tasks that run if a required task failed.
+	Hooks []string `yaml:"hooks"`
+}
+
+// UnmarshalYAML reads the policy, the hooks or a mapping of both.
+func (f *Failure) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var policy string
+	if err := unmarshal(&policy); err == nil {
+		*f = Failure{Policy: policy}
+		return nil
+	}
+	var hooks []string
+	if err := unmarshal(&hooks); err == nil {
+		*f = Failure{Hooks: hooks}
+		return nil
+	}
+	type failure Failure
+	return unmarshal((*failure)(f))
+}
+
+// Exit codes of a workflow run, see RunError.ExitCode.
+const (
+	ExitSucceeded        = 0
+	ExitFailed           = 1
+	ExitOptionalFailures = 2
+)
+
+// RunError is returned by Run if tasks failed.
+type RunError struct {
+	// Failed contains the errors of failed tasks by task name.
+	Failed map[string]string
+	// Allowed contains the errors of failed tasks that allow failures.
+	Allowed map[string]string
+	// Cancelled is set if the run was cancelled before all tasks finished.
+	Cancelled bool
+}
+
+func (e *RunError) Error() string {
+	lines := failureLines(e.Failed, "")
+	lines = append(lines, failureLines(e.Allowed, " (failure allowed)")...)
+	if len(lines) == 1 {
+		return lines[0]
+	}
+	return fmt.Sprintf("%d tasks failed:\n- %s", len(lines), strings.Join(lines, "\n- "))
+}
+
+// Is matches context.Canceled if the run was cancelled.
+func (e *RunError) Is(target error) bool {
+	return e.Cancelled && target == context.Canceled
+}
+
+func failureLines(failures map[string]string, suffix string) []string {
+	var names []string
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("task %s failed: %s%s", name, failures[name], suffix))
+	}
+	return lines
+}
+
+// ExitCode returns ExitFailed if a required task failed and
+// ExitOptionalFailures if only tasks that allow failures failed.
+func (e *RunError) ExitCode() int {
+	if len(e.Failed) > 0 {
+		return ExitFailed
+	}
+	return ExitOptionalFailures
+}
+
+// runError collects the failed tasks of the run.
+func (r *run) runError() error {
+	r.statusMux.Lock()
+	defer r.statusMux.Unlock()
+
+	runErr := &RunError{
+		Failed:    map[string]string{},
+		Allowed:   map[string]string{},
+		Cancelled: errors.Is(r.ctx.Err(), context.Canceled),
+	}
+	for name, report := range r.reports {
+		if !report.Status.failed() || report.parent != "" || report.hook {
+			continue
+		}
+		if r.tasks[name].AllowFailure {
+			runErr.Allowed[name] = report.Error
+		} else {
+			runErr.Failed[name] = report.Error
+		}
+	}
+	if len(runErr.Failed) == 0 && len(runErr.Allowed) == 0 {
+		return nil
+	}
+	return runErr
+}
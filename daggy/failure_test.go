@@ -0,0 +1,139 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWorkflow_RunFailurePolicy(t *testing.T) {
+	// slow only finishes once it is cancelled by the stopped run, so d is
+	// never started. late fails after slow was started.
+	started := make(chan struct{})
+	plugins := map[string]testCommand{
+		"ok":   {},
+		"fail": fails("broken"),
+		"late": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+			<-started
+			return errors.New("broken")
+		}},
+		"slow": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}
+
+	tests := []struct {
+		name         string
+		workflow     Workflow
+		wantExitCode int
+		wantStatuses map[string]Status
+	}{
+		{"all passed", Workflow{Tasks: map[string]Task{
+			"a": {Command: "ok"},
+			"b": {Command: "ok", Requires: []string{"a"}},
+		}}, ExitSucceeded, map[string]Status{"a": StatusSucceeded, "b": StatusSucceeded}},
+		{"optional failure", Workflow{Tasks: map[string]Task{
+			"a": {Command: "fail", AllowFailure: true},
+			"b": {Command: "ok", Requires: []string{"a"}},
+			"c": {Command: "ok"},
+		}}, ExitOptionalFailures, map[string]Status{"a": StatusFailed, "b": StatusSkipped, "c": StatusSucceeded}},
+		{"required failure", Workflow{Tasks: map[string]Task{
+			"a": {Command: "fail"},
+			"b": {Command: "ok", Requires: []string{"a"}},
+			"c": {Command: "fail", AllowFailure: true},
+		}}, ExitFailed, map[string]Status{"a": StatusFailed, "b": StatusSkipped, "c": StatusFailed}},
+		{"stop", Workflow{Tasks: map[string]Task{
+			"a": {Command: "late"},
+			"b": {Command: "ok", Requires: []string{"a"}},
+			"c": {Command: "slow"},
+			"d": {Command: "ok", Requires: []string{"c"}},
+		}, OnFailure: Failure{Policy: OnFailureStop}, Parallelism: 2}, ExitFailed, map[string]Status{"a": StatusFailed, "b": StatusSkipped, "c": StatusCancelled, "d": StatusSkipped}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.workflow.NoCache = true
+			tt.workflow.SetupGraph()
+
+			report, err := tt.workflow.RunWithReport(context.Background(), "test.forensicstore", executors(plugins))
+			exitCode := ExitSucceeded
+			if err != nil {
+				var runErr *RunError
+				if !errors.As(err, &runErr) {
+					t.Fatalf("RunWithReport() error = %v, want RunError", err)
+				}
+				exitCode = runErr.ExitCode()
+			}
+			if exitCode != tt.wantExitCode {
+				t.Errorf("RunWithReport() exit code = %d, want %d (%v)", exitCode, tt.wantExitCode, err)
+			}
+
+			statuses := map[string]Status{}
+			for _, task := range report.Tasks {
+				if _, ok := tt.wantStatuses[task.Name]; ok {
+					statuses[task.Name] = task.Status
+				}
+			}
+			if !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("RunWithReport() statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunUnknownFailurePolicy(t *testing.T) {
+	workflow := Workflow{Tasks: map[string]Task{"a": {Command: "ok"}}, OnFailure: Failure{Policy: "abort"}}
+	workflow.SetupGraph()
+	if err := workflow.Run(context.Background(), "test.forensicstore", nil); err == nil {
+		t.Error("Run() expected error for unknown failure policy")
+	}
+}
+
+func TestWorkflow_RunInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the task is interrupted while it runs
+	plugins := map[string]testCommand{"interrupt": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+		cancel()
+		<-ctx.Done()
+		return errors.New("killed")
+	}}}
+	workflow := Workflow{Tasks: map[string]Task{"a": {Command: "interrupt"}}, NoCache: true}
+	workflow.SetupGraph()
+
+	err := workflow.Run(ctx, "test.forensicstore", executors(plugins))
+	var runErr *RunError
+	if !errors.As(err, &runErr) || runErr.ExitCode() != ExitFailed {
+		t.Fatalf("Run() error = %v, want RunError", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want cancelled", err)
+	}
+	if errors.Is(&RunError{Failed: runErr.Failed}, context.Canceled) {
+		t.Error("RunError without cancellation matches context.Canceled")
+	}
+}
@@ -32,6 +32,49 @@ import (
 // A Filter is a list of mappings that should be used for a Task.
 type Filter []map[string]string
 
+// engineTypes are the types of the elements the workflow engine adds to the
+// forensicstore: runs, tasks and the provenance of runs.
+var engineTypes = map[string]bool{ // nolint: gochecknoglobals
+	"workflow_run": true, "workflow_task": true, "provenance": true,
+}
+
+// ParseFilter parses filtersets like "type=file,name=%.evtx" into a filter
+// with a condition per filterset.
+func ParseFilter(filtersets ...string) Filter {
+	var filter Filter
+	for _, filterset := range filtersets {
+		filter = append(filter, parseFilterset(filterset))
+	}
+	return filter
+}
+
+// Select returns the elements of the forensicstore that match the filter.
+// Elements of the workflow engine are only selected by conditions on their
+// type, e.g. type=workflow_run.
+func (f Filter) Select(store *forensicstore.ForensicStore) ([]forensicstore.JSONElement, error) {
+	elements, err := store.Select(f)
+	if err != nil {
+		return nil, err
+	}
+	selected := elements[:0]
+	for _, element := range elements {
+		elementType := gjson.GetBytes(element, "type").String()
+		if !engineTypes[elementType] || f.selectsType(elementType) {
+			selected = append(selected, element)
+		}
+	}
+	return selected, nil
+}
+
+func (f Filter) selectsType(elementType string) bool {
+	for _, condition := range f {
+		if condition["type"] == elementType {
+			return true
+		}
+	}
+	return false
+}
+
 // Match tests if an element matches the filter.
 func (f Filter) Match(element forensicstore.JSONElement) bool {
 	if len(f) == 0 {
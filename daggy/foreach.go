@@ -0,0 +1,178 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+// Foreach runs a task once per item. The items are either listed or
+// collected from the distinct values of a field in the forensicstore
+// elements that match the filter. The current item can be referenced as
+// ${item} in the task arguments.
+type Foreach struct {
+	Items  []string `yaml:"items"`
+	Filter Filter   `yaml:"filter"`
+	// Field is a gjson path, e.g. "name" or "origin.path".
+	Field string `yaml:"field"`
+}
+
+// values returns the distinct items and field values from the forensicstore.
+func (f *Foreach) values(storeDir string) ([]string, error) {
+	var values []string
+	seen := map[string]bool{}
+	for _, item := range f.Items {
+		if !seen[item] {
+			seen[item] = true
+			values = append(values, item)
+		}
+	}
+	if f.Field == "" {
+		if f.Filter != nil {
+			return nil, fmt.Errorf("foreach filter requires a field")
+		}
+		return values, nil
+	}
+
+	store, teardown, err := forensicstore.Open(storeDir)
+	if err != nil {
+		return nil, err
+	}
+	defer teardown()
+
+	elements, err := f.Filter.Select(store)
+	if err != nil {
+		return nil, err
+	}
+
+	var fieldValues []string
+	for _, element := range elements {
+		value := gjson.GetBytes(element, f.Field).String()
+		if value != "" && !seen[value] {
+			seen[value] = true
+			fieldValues = append(fieldValues, value)
+		}
+	}
+	sort.Strings(fieldValues)
+	return append(values, fieldValues...), nil
+}
+
+// forItem returns the sub-task of a foreach task for a single item. The
+// directories of its outputs are created.
+func (workflow *Workflow) forItem(name string, task Task, item, workdir string) (Task, error) {
+	arguments, err := interpolate(task.Arguments, workflow.lookupTask(name, workdir, item))
+	if err != nil {
+		return task, err
+	}
+	task.Arguments, _ = arguments.(map[string]interface{})
+	task.Foreach = nil
+
+	if outputs := workflow.Tasks[name].Outputs; outputs != nil {
+		task.Outputs = map[string]string{}
+		for key := range outputs {
+			output, err := workflow.outputPath(workdir, name, key, item)
+			if err != nil {
+				return task, err
+			}
+			if err := os.MkdirAll(filepath.Dir(output), 0700); err != nil {
+				return task, fmt.Errorf("could not create working directory: %w", err)
+			}
+			task.Outputs[key] = output
+		}
+	}
+	return task, nil
+}
+
+// subTaskName returns the name of the sub-task of a foreach task.
+func subTaskName(name, item string) string {
+	return fmt.Sprintf("%s[%s]", name, item)
+}
+
+// fanOut runs a sub-task for every foreach item. The task fails if any
+// sub-task fails, it is cancelled or timed out if all failed sub-tasks were.
+func (r *run) fanOut(name string, executor Executor, task Task) (Status, error) {
+	r.limits.store.RLock()
+	items, err := task.Foreach.values(r.storeDir)
+	r.limits.store.RUnlock()
+	if err != nil {
+		return StatusFailed, fmt.Errorf("could not get foreach items: %w", err)
+	}
+	if len(items) == 0 {
+		log.Printf("skip task %s: no foreach items", name)
+		return StatusSkipped, nil
+	}
+
+	errs := make([]error, len(items))
+	statuses := make([]Status, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item string) {
+			defer wg.Done()
+			subName := subTaskName(name, item)
+			r.emit(Event{Type: TaskQueued, Task: subName, Parent: name})
+			start := time.Now()
+
+			status := StatusFailed
+			subTask, err := r.workflow.forItem(name, task, item, r.workdir)
+			if err == nil {
+				status, err = r.perform(subName, executor, subTask)
+			}
+			if err != nil {
+				log.Printf("task %s failed: %s", subName, err)
+			}
+			r.setStatus(subName, status)
+			r.recordTask(subName, name, subTask, status, start, err)
+			errs[i] = err
+			statuses[i] = status
+		}(i, item)
+	}
+	wg.Wait()
+
+	var failed []string
+	var status Status
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", items[i], err))
+			if status == "" {
+				status = statuses[i]
+			} else if status != statuses[i] {
+				status = StatusFailed
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return status, fmt.Errorf("%d of %d items failed: %s", len(failed), len(items), strings.Join(failed, "; "))
+	}
+	return StatusSucceeded, nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestForeach_values(t *testing.T) {
+	storeDir, cleanupStore := newTestStore(t,
+		`{"type": "test", "name": "b.evtx", "origin": {"user": "bob"}}`,
+		`{"type": "test", "name": "a.evtx", "origin": {"user": "alice"}}`,
+		`{"type": "test", "name": "c.evtx", "origin": {"user": "alice"}}`,
+		`{"type": "test", "name": "d.pf"}`,
+	)
+	defer cleanupStore()
+
+	tests := []struct {
+		name    string
+		foreach Foreach
+		want    []string
+		wantErr bool
+	}{
+		{"items", Foreach{Items: []string{"file", "process"}}, []string{"file", "process"}, false},
+		{"duplicate items", Foreach{Items: []string{"file", "process", "file"}}, []string{"file", "process"}, false},
+		{"field", Foreach{Filter: Filter{{"name": "%.evtx"}}, Field: "name"}, []string{"a.evtx", "b.evtx", "c.evtx"}, false},
+		{"distinct nested field", Foreach{Filter: Filter{{"type": "test"}}, Field: "origin.user"}, []string{"alice", "bob"}, false},
+		{"items and field", Foreach{Items: []string{"bob"}, Filter: Filter{{"type": "test"}}, Field: "origin.user"}, []string{"bob", "alice"}, false},
+		{"no match", Foreach{Filter: Filter{{"name": "%.lnk"}}, Field: "name"}, nil, false},
+		{"filter without field", Foreach{Filter: Filter{{"type": "test"}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.foreach.values(storeDir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("values() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("values() got = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunForeach(t *testing.T) {
+	storeDir, cleanupStore := newTestStore(t,
+		`{"type": "test", "name": "a.evtx"}`,
+		`{"type": "test", "name": "b.evtx"}`,
+	)
+	defer cleanupStore()
+
+	var mux sync.Mutex
+	var ran []string
+	commands := executors(map[string]testCommand{"example": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+		mux.Lock()
+		defer mux.Unlock()
+		name, _ := spec.Arguments["name"].(string)
+		ran = append(ran, name)
+		return nil
+	}}})
+
+	tests := []struct {
+		name    string
+		foreach *Foreach
+		want    []string
+	}{
+		{"items", &Foreach{Items: []string{"x", "${prefix}y"}}, []string{"item-vy", "item-x"}},
+		{"store", &Foreach{Filter: Filter{{"type": "test"}}, Field: "name"}, []string{"item-a.evtx", "item-b.evtx"}},
+		{"no items", &Foreach{Filter: Filter{{"type": "none"}}, Field: "name"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran = nil
+			workflow := Workflow{
+				Tasks: map[string]Task{"test": {
+					Command:   "example",
+					Arguments: map[string]interface{}{"name": "item-${item}"},
+					Foreach:   tt.foreach,
+				}},
+				Vars:        map[string]string{"prefix": "v"},
+				NoCache:     true,
+				Parallelism: 2,
+			}
+			workflow.SetupGraph()
+
+			if err := workflow.Run(context.Background(), storeDir, commands); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(ran)
+			if !reflect.DeepEqual(ran, tt.want) {
+				t.Errorf("Run() ran %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
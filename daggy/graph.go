@@ -0,0 +1,223 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+// statusColors are the fill colors of tasks in rendered graphs.
+var statusColors = map[Status]string{ // nolint: gochecknoglobals
+	StatusSucceeded: "#a3d9a5",
+	StatusFailed:    "#f4a6a6",
+	StatusSkipped:   "#d9d9d9",
+	StatusCached:    "#a6c8f4",
+	StatusCancelled: "#f4d6a6",
+	StatusTimedOut:  "#f4c0a6",
+}
+
+// WriteDOT renders the task graph in the Graphviz DOT format, hooks are
+// grouped by hook point. Tasks are colored by their status if statuses is
+// not nil.
+func (workflow *Workflow) WriteDOT(w io.Writer, statuses map[string]Status) error {
+	names, edges := workflow.graphElements()
+
+	b := &strings.Builder{}
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+	node := func(indent, id, name string) {
+		fmt.Fprintf(b, "%s%s [label=%s", indent, quoteDOT(id), quoteDOT(name+"\n"+workflow.Tasks[name].Command))
+		if color, ok := statusColors[statuses[id]]; ok {
+			fmt.Fprintf(b, ", fillcolor=%q, tooltip=%q", color, statuses[id])
+		}
+		b.WriteString("];\n")
+	}
+	for _, name := range names {
+		node("  ", name, name)
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(b, "  %s -> %s;\n", quoteDOT(edge[0]), quoteDOT(edge[1]))
+	}
+	hooks := workflow.hooks()
+	for _, point := range hookPoints {
+		if len(hooks[point]) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "  subgraph %s {\n", quoteDOT("cluster_"+point))
+		fmt.Fprintf(b, "    label=%s;\n    style=dashed;\n", quoteDOT(point))
+		for _, name := range hooks[point] {
+			node("    ", point+"."+name, name)
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid renders the task graph as Mermaid flowchart, hooks are
+// grouped by hook point. Tasks are colored by their status if statuses is
+// not nil.
+func (workflow *Workflow) WriteMermaid(w io.Writer, statuses map[string]Status) error {
+	names, edges := workflow.graphElements()
+
+	ids := map[string]string{}
+	for i, name := range names {
+		ids[name] = fmt.Sprintf("task%d", i)
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("graph LR\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "  %s[\"%s<br/>%s\"]\n", ids[name], escapeMermaid(name), escapeMermaid(workflow.Tasks[name].Command))
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(b, "  %s --> %s\n", ids[edge[0]], ids[edge[1]])
+	}
+	hooks := workflow.hooks()
+	hookCount := 0
+	for _, point := range hookPoints {
+		if len(hooks[point]) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "  subgraph %s\n", point)
+		for _, name := range hooks[point] {
+			id := point + "." + name
+			ids[id] = fmt.Sprintf("hook%d", hookCount)
+			hookCount++
+			names = append(names, id)
+			fmt.Fprintf(b, "    %s[\"%s<br/>%s\"]\n", ids[id], escapeMermaid(name), escapeMermaid(workflow.Tasks[name].Command))
+		}
+		b.WriteString("  end\n")
+	}
+	if statuses != nil {
+		for _, status := range []Status{
+			StatusSucceeded, StatusFailed, StatusSkipped, StatusCached, StatusCancelled, StatusTimedOut,
+		} {
+			var classed []string
+			for _, name := range names {
+				if statuses[name] == status {
+					classed = append(classed, ids[name])
+				}
+			}
+			if len(classed) > 0 {
+				fmt.Fprintf(b, "  classDef %s fill:%s\n", status, statusColors[status])
+				fmt.Fprintf(b, "  class %s %s\n", strings.Join(classed, ","), status)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// graphElements returns the sorted task names and edges of the graph
+// created by SetupGraph.
+func (workflow *Workflow) graphElements() (names []string, edges [][2]string) {
+	if workflow.graph == nil {
+		workflow.SetupGraph()
+	}
+	for _, vertex := range workflow.graph.Vertices() {
+		names = append(names, fmt.Sprint(vertex))
+	}
+	sort.Strings(names)
+	for _, edge := range workflow.graph.Edges() {
+		edges = append(edges, [2]string{fmt.Sprint(edge.Source()), fmt.Sprint(edge.Target())})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return names, edges
+}
+
+func quoteDOT(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func escapeMermaid(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// LastStatuses returns the task states of the last run of the workflow that
+// is recorded in the forensicstore.
+func (workflow *Workflow) LastStatuses(storeDir string) (map[string]Status, error) {
+	store, teardown, err := forensicstore.Open(storeDir)
+	if err != nil {
+		return nil, err
+	}
+	defer teardown()
+
+	elements, err := store.Select(Filter{{"type": "workflow_run", "workflow": workflow.Hash()}})
+	if err != nil {
+		return nil, err
+	}
+	var last string
+	var lastStart time.Time
+	for _, element := range elements {
+		state := runState{}
+		if err := json.Unmarshal(element, &state); err != nil {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339Nano, state.StartTime)
+		if err != nil {
+			continue
+		}
+		if last == "" || start.After(lastStart) {
+			last, lastStart = state.ID, start
+		}
+	}
+	if last == "" {
+		return nil, errors.New("no run of the workflow found")
+	}
+
+	elements, err = store.Select(Filter{{"type": "workflow_task", "run": last}})
+	if err != nil {
+		return nil, err
+	}
+	statuses := map[string]Status{}
+	for _, element := range elements {
+		state := taskState{}
+		if err := json.Unmarshal(element, &state); err != nil {
+			continue
+		}
+		if state.Parent == "" {
+			statuses[state.Task] = state.Status
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func testGraphWorkflow() *Workflow {
+	workflow := &Workflow{Tasks: map[string]Task{
+		"prefetch":        {Command: "prefetch"},
+		"prefetch_report": {Command: "report", Requires: []string{"prefetch"}},
+	}}
+	workflow.SetupGraph()
+	return workflow
+}
+
+func TestWorkflow_WriteDOT(t *testing.T) {
+	want := `digraph workflow {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled", fillcolor=white];
+  "prefetch" [label="prefetch\nprefetch", fillcolor="#a3d9a5", tooltip="succeeded"];
+  "prefetch_report" [label="prefetch_report\nreport"];
+  "prefetch" -> "prefetch_report";
+}
+`
+	b := &bytes.Buffer{}
+	if err := testGraphWorkflow().WriteDOT(b, map[string]Status{"prefetch": StatusSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want {
+		t.Errorf("WriteDOT() = %s, want %s", b.String(), want)
+	}
+}
+
+func TestWorkflow_WriteMermaid(t *testing.T) {
+	want := `graph LR
+  task0["prefetch<br/>prefetch"]
+  task1["prefetch_report<br/>report"]
+  task0 --> task1
+  classDef failed fill:#f4a6a6
+  class task0 failed
+  classDef skipped fill:#d9d9d9
+  class task1 skipped
+`
+	b := &bytes.Buffer{}
+	statuses := map[string]Status{"prefetch": StatusFailed, "prefetch_report": StatusSkipped}
+	if err := testGraphWorkflow().WriteMermaid(b, statuses); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want {
+		t.Errorf("WriteMermaid() = %s, want %s", b.String(), want)
+	}
+}
+
+func TestWorkflow_WriteMermaidHooks(t *testing.T) {
+	want := `graph LR
+  task0["prefetch<br/>prefetch"]
+  subgraph on_start
+    hook0["a<br/>mark"]
+    hook1["b<br/>mark"]
+  end
+  subgraph finally
+    hook2["c<br/>export"]
+  end
+  classDef succeeded fill:#a3d9a5
+  class hook1 succeeded
+  classDef failed fill:#f4a6a6
+  class hook2 failed
+`
+	workflow := &Workflow{Tasks: map[string]Task{
+		"prefetch": {Command: "prefetch"},
+		"a":        {Command: "mark"},
+		"b":        {Command: "mark"},
+		"c":        {Command: "export"},
+	}, OnStart: []string{"a", "b"}, Finally: []string{"c"}}
+	workflow.SetupGraph()
+
+	b := &bytes.Buffer{}
+	statuses := map[string]Status{"on_start.b": StatusSucceeded, "finally.c": StatusFailed}
+	if err := workflow.WriteMermaid(b, statuses); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want {
+		t.Errorf("WriteMermaid() = %s, want %s", b.String(), want)
+	}
+}
+
+func TestWorkflow_LastStatuses(t *testing.T) {
+	storeDir, cleanupStore := newTestStore(t)
+	defer cleanupStore()
+
+	workflow := &Workflow{Tasks: map[string]Task{
+		"a": {Command: "ok"},
+		"b": {Command: "fail"},
+	}}
+	workflow.SetupGraph()
+
+	if _, err := workflow.LastStatuses(storeDir); err == nil {
+		t.Error("LastStatuses() expected error without runs")
+	}
+
+	plugins := map[string]testCommand{"ok": {}, "fail": fails("fail")}
+	_ = workflow.Run(context.Background(), storeDir, executors(plugins))
+
+	got, err := workflow.LastStatuses(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Status{"a": StatusSucceeded, "b": StatusFailed}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LastStatuses() = %v, want %v", got, want)
+	}
+
+	// runs of a selection are runs of the workflow
+	selection := &Workflow{Tasks: workflow.Tasks}
+	if err := selection.Select(nil, []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := selection.Run(context.Background(), storeDir, executors(plugins)); err != nil {
+		t.Fatal(err)
+	}
+	got, err = workflow.LastStatuses(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]Status{"a": StatusSucceeded}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LastStatuses() after selection = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// Hook points of a workflow run. Hooks are tasks that run at these points
+// instead of as part of the graph, e.g. to export a summary or to snapshot
+// the forensicstore. The path of a JSON report of the run so far is
+// available as ${report}. The hooks of a point run one after another in the
+// given order. Failed hooks are logged and reported, but never change the
+// result of the run. Hooks also run if the workflow was cancelled, limited
+// by their timeout or hookTimeout. Hooks cannot require tasks or be required
+// and do not support when, foreach and outputs.
+const (
+	HookOnStart   = "on_start"
+	HookOnSuccess = "on_success"
+	HookOnFailure = "on_failure"
+	HookFinally   = "finally"
+)
+
+// hookTimeout limits hooks without a timeout. Hooks also run after the
+// workflow was cancelled, so they need a limit of their own.
+const hookTimeout = 10 * time.Minute
+
+// hookPoints are the hook points in the order they run.
+var hookPoints = []string{HookOnStart, HookOnSuccess, HookOnFailure, HookFinally} // nolint: gochecknoglobals
+
+// hooks returns the names of the hooks by hook point.
+func (workflow *Workflow) hooks() map[string][]string {
+	return map[string][]string{
+		HookOnStart:   workflow.OnStart,
+		HookOnSuccess: workflow.OnSuccess,
+		HookOnFailure: workflow.OnFailure.Hooks,
+		HookFinally:   workflow.Finally,
+	}
+}
+
+// isHook checks if the task runs as hook.
+func (workflow *Workflow) isHook(name string) bool {
+	for _, hooks := range workflow.hooks() {
+		for _, hook := range hooks {
+			if hook == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// begin runs the on_start hooks before the first task.
+func (r *run) begin(start time.Time) {
+	if len(r.workflow.OnStart) == 0 {
+		return
+	}
+	report := r.report(start, nil)
+	report.Status = StatusRunning
+	r.runHooks(HookOnStart, r.workflow.OnStart, report)
+}
+
+// finish runs the on_success or on_failure hooks and the finally hooks
+// after all tasks.
+func (r *run) finish(start time.Time, runErr error) {
+	report := r.report(start, runErr)
+	if report.Status == StatusSucceeded {
+		r.runHooks(HookOnSuccess, r.workflow.OnSuccess, report)
+	} else {
+		r.runHooks(HookOnFailure, r.workflow.OnFailure.Hooks, report)
+	}
+	if len(r.workflow.Finally) > 0 {
+		r.runHooks(HookFinally, r.workflow.Finally, r.report(start, runErr))
+	}
+}
+
+// runHooks runs the hooks of a hook point. Hooks are reported as
+// <point>.<name>.
+func (r *run) runHooks(point string, hooks []string, report *Report) {
+	if len(hooks) == 0 {
+		return
+	}
+
+	reportFile := filepath.Join(r.workdir, point+".report.json")
+	if err := report.WriteFile(reportFile); err != nil {
+		log.Printf("could not write report for %s hooks: %s", point, err)
+		return
+	}
+
+	for _, name := range hooks {
+		hookName := point + "." + name
+		r.emit(Event{Type: TaskQueued, Task: hookName})
+		start := time.Now()
+		task, status, err := r.runHook(hookName, r.workflow.Tasks[name], reportFile)
+
+		r.statusMux.Lock()
+		r.taskReport(hookName).hook = true
+		r.statusMux.Unlock()
+		r.recordTask(hookName, "", task, status, start, err)
+
+		if err != nil {
+			log.Printf("hook %s failed: %s", hookName, err)
+		}
+	}
+}
+
+func (r *run) runHook(name string, task Task, reportFile string) (Task, Status, error) {
+	task, err := resolveTask(task, r.workflow.lookupHook(r.workdir, reportFile))
+	if err != nil {
+		return task, StatusFailed, err
+	}
+
+	executor, ok := r.executors[task.Command]
+	if !ok {
+		return task, StatusFailed, fmt.Errorf("command %s not found", task.Command)
+	}
+
+	timeout := hookTimeout
+	if task.Timeout > 0 {
+		timeout = time.Duration(task.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(detached{r.ctx}, timeout)
+	defer cancel()
+	if err := r.runAttempts(ctx, executor, name, task); err != nil {
+		return task, failedStatus(err), err
+	}
+	return task, StatusSucceeded, nil
+}
+
+// detached keeps the values of a context but is never cancelled, so the
+// finally hooks run even if the workflow was interrupted.
+type detached struct{ context.Context }
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
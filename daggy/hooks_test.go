@@ -0,0 +1,140 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestWorkflow_RunHooks(t *testing.T) {
+	// statuses of the reports passed to the hooks by hook name
+	var seen map[string]Status
+	hookCmd := testCommand{run: func(ctx context.Context, store string, spec TaskSpec) error {
+		reportFile, _ := spec.Arguments["report"].(string)
+		name, _ := spec.Arguments["name"].(string)
+		b, err := ioutil.ReadFile(reportFile)
+		if err != nil {
+			return err
+		}
+		report := Report{}
+		if err := json.Unmarshal(b, &report); err != nil {
+			return err
+		}
+		seen[name] = report.Status
+		return nil
+	}}
+
+	plugins := map[string]testCommand{"ok": {}, "fail": fails("broken"), "hook": hookCmd}
+	hook := func(name string) Task {
+		return Task{Command: "hook", Arguments: map[string]interface{}{"report": "${report}", "name": name}}
+	}
+	hookTasks := map[string]Task{
+		"start":   hook("start"),
+		"success": hook("success"),
+		"failure": hook("failure"),
+		"finally": hook("finally"),
+		"broken":  {Command: "fail"},
+	}
+	hooks := Workflow{
+		OnStart:   []string{"start"},
+		OnSuccess: []string{"success"},
+		OnFailure: Failure{Hooks: []string{"failure"}},
+		Finally:   []string{"broken", "finally"},
+	}
+
+	failingStart := hooks
+	failingStart.OnStart = []string{"broken", "start"}
+
+	tests := []struct {
+		name      string
+		tasks     map[string]Task
+		hooks     Workflow
+		cancelled bool
+		wantErr   bool
+		wantSeen  map[string]Status
+		wantHooks map[string]Status
+	}{
+		{"success", map[string]Task{"a": {Command: "ok"}}, hooks, false, false,
+			map[string]Status{"start": StatusRunning, "success": StatusSucceeded, "finally": StatusSucceeded},
+			map[string]Status{"on_start.start": StatusSucceeded, "on_success.success": StatusSucceeded, "finally.broken": StatusFailed, "finally.finally": StatusSucceeded},
+		},
+		{"optional failure", map[string]Task{"a": {Command: "fail", AllowFailure: true}}, hooks, false, true,
+			map[string]Status{"start": StatusRunning, "success": StatusSucceeded, "finally": StatusSucceeded},
+			map[string]Status{"on_start.start": StatusSucceeded, "on_success.success": StatusSucceeded, "finally.broken": StatusFailed, "finally.finally": StatusSucceeded},
+		},
+		{"failure", map[string]Task{"a": {Command: "fail"}}, hooks, false, true,
+			map[string]Status{"start": StatusRunning, "failure": StatusFailed, "finally": StatusFailed},
+			map[string]Status{"on_start.start": StatusSucceeded, "on_failure.failure": StatusSucceeded, "finally.broken": StatusFailed, "finally.finally": StatusSucceeded},
+		},
+		{"on_start failure", map[string]Task{"a": {Command: "ok"}}, failingStart, false, false,
+			map[string]Status{"start": StatusRunning, "success": StatusSucceeded, "finally": StatusSucceeded},
+			map[string]Status{"on_start.broken": StatusFailed, "on_start.start": StatusSucceeded, "on_success.success": StatusSucceeded, "finally.broken": StatusFailed, "finally.finally": StatusSucceeded},
+		},
+		{"cancelled", map[string]Task{"a": {Command: "ok"}}, hooks, true, true,
+			map[string]Status{"start": StatusRunning, "failure": StatusFailed, "finally": StatusFailed},
+			map[string]Status{"on_start.start": StatusSucceeded, "on_failure.failure": StatusSucceeded, "finally.broken": StatusFailed, "finally.finally": StatusSucceeded},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = map[string]Status{}
+			workflow := tt.hooks
+			workflow.Tasks = map[string]Task{}
+			for name, task := range hookTasks {
+				workflow.Tasks[name] = task
+			}
+			for name, task := range tt.tasks {
+				workflow.Tasks[name] = task
+			}
+			workflow.NoCache = true
+			workflow.SetupGraph()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			report, err := workflow.RunWithReport(ctx, "test.forensicstore", executors(plugins))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunWithReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(seen, tt.wantSeen) {
+				t.Errorf("RunWithReport() hooks saw reports %v, want %v", seen, tt.wantSeen)
+			}
+
+			hookStatuses := map[string]Status{}
+			for _, hook := range report.Hooks {
+				hookStatuses[hook.Name] = hook.Status
+			}
+			if !reflect.DeepEqual(hookStatuses, tt.wantHooks) {
+				t.Errorf("RunWithReport() hooks = %v, want %v", hookStatuses, tt.wantHooks)
+			}
+			if len(report.Tasks) != len(tt.tasks) {
+				t.Errorf("RunWithReport() got %d tasks, want %d", len(report.Tasks), len(tt.tasks))
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// limits coordinates concurrently running tasks. It bounds the overall number
+// of running tasks, the number of running tasks per concurrency class and
+// ensures that only a single task writes to the forensicstore at a time.
+type limits struct {
+	slots   chan struct{}
+	classes map[string]chan struct{}
+
+	store sync.RWMutex
+}
+
+func newLimits(workflow *Workflow) *limits {
+	parallelism := workflow.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	l := &limits{
+		slots:   make(chan struct{}, parallelism),
+		classes: map[string]chan struct{}{},
+	}
+	for _, task := range workflow.Tasks {
+		if task.Class == "" {
+			continue
+		}
+		if _, ok := l.classes[task.Class]; ok {
+			continue
+		}
+		classLimit, ok := workflow.Classes[task.Class]
+		if !ok || classLimit <= 0 {
+			classLimit = 1
+		}
+		l.classes[task.Class] = make(chan struct{}, classLimit)
+	}
+	return l
+}
+
+// acquire blocks until the task is allowed to run or the context is done.
+// The returned function must be called to release all acquired resources.
+func (l *limits) acquire(ctx context.Context, info CommandInfo, task Task) (release func(), err error) {
+	var releases []func()
+	release = func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	if class, ok := l.classes[task.Class]; ok {
+		select {
+		case class <- struct{}{}:
+			releases = append(releases, func() { <-class })
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		releases = append(releases, func() { <-l.slots })
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+
+	if writesStore(info, task) {
+		l.store.Lock()
+		releases = append(releases, l.store.Unlock)
+	} else {
+		l.store.RLock()
+		releases = append(releases, l.store.RUnlock)
+	}
+
+	if ctx.Err() != nil {
+		release()
+		return nil, ctx.Err()
+	}
+	return release, nil
+}
+
+// writesStore checks if a task inserts elements into the forensicstore.
+func writesStore(info CommandInfo, task Task) bool {
+	if info.WritesStore {
+		return true
+	}
+	if addToStore, ok := task.Arguments["add-to-store"]; ok {
+		return fmt.Sprint(addToStore) == "true"
+	}
+	return false
+}
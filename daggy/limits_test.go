@@ -0,0 +1,50 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"testing"
+)
+
+func Test_writesStore(t *testing.T) {
+	type args struct {
+		info CommandInfo
+		task Task
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"read only", args{CommandInfo{}, Task{}}, false},
+		{"direct insert", args{CommandInfo{WritesStore: true}, Task{}}, true},
+		{"add to store", args{CommandInfo{}, Task{Arguments: map[string]interface{}{"add-to-store": true}}}, true},
+		{"not add to store", args{CommandInfo{}, Task{Arguments: map[string]interface{}{"add-to-store": false}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writesStore(tt.args.info, tt.args.task); got != tt.want {
+				t.Errorf("writesStore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
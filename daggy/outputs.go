@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// outputPath returns the path of an output of a task in the working
+// directory. Outputs of foreach tasks are stored per item in
+// <workdir>/<task>[<item>] and can contain the item as ${item}, so they are
+// only available to the task itself.
+func (workflow *Workflow) outputPath(workdir, name, key, item string) (string, error) {
+	task, ok := workflow.Tasks[name]
+	if !ok {
+		return "", fmt.Errorf("unknown task %s", name)
+	}
+	file, ok := task.Outputs[key]
+	if !ok {
+		return "", fmt.Errorf("task %s has no output %s", name, key)
+	}
+
+	dir, lookup := name, workflow.lookup
+	if task.Foreach != nil {
+		if item == "" {
+			return "", fmt.Errorf("outputs of foreach task %s are per item and cannot be used by other tasks", name)
+		}
+		// items must not add directories or leave the working directory
+		item = strings.NewReplacer("/", "_", `\`, "_").Replace(item)
+		dir = subTaskName(name, item)
+		lookup = func(ref string) (string, error) {
+			if ref == "item" {
+				return item, nil
+			}
+			return workflow.lookup(ref)
+		}
+	}
+
+	file, err := interpolateString(file, lookup)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(workdir, dir, filepath.FromSlash(file)), nil
+}
+
+// requires checks if a task requires another task directly or transitively.
+func (workflow *Workflow) requires(name, required string) bool {
+	seen := map[string]bool{}
+	queue := []string{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, requirement := range workflow.Tasks[current].Requires {
+			if requirement == required {
+				return true
+			}
+			if !seen[requirement] {
+				seen[requirement] = true
+				queue = append(queue, requirement)
+			}
+		}
+	}
+	return false
+}
+
+// prefixReferences adds the namespace to references on outputs of other
+// tasks in the arguments of an included task.
+func prefixReferences(value interface{}, namespace string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return variablePattern.ReplaceAllStringFunc(v, func(match string) string {
+			ref := strings.TrimSpace(match[2 : len(match)-1])
+			if strings.HasPrefix(match, "$$") || !strings.HasPrefix(ref, "tasks.") {
+				return match
+			}
+			return "${tasks." + namespace + "." + strings.TrimPrefix(ref, "tasks.") + "}"
+		})
+	case []interface{}:
+		prefixed := make([]interface{}, len(v))
+		for i, item := range v {
+			prefixed[i] = prefixReferences(item, namespace)
+		}
+		return prefixed
+	case map[string]interface{}:
+		prefixed := map[string]interface{}{}
+		for key, item := range v {
+			prefixed[key] = prefixReferences(item, namespace)
+		}
+		return prefixed
+	case map[interface{}]interface{}:
+		prefixed := map[interface{}]interface{}{}
+		for key, item := range v {
+			prefixed[key] = prefixReferences(item, namespace)
+		}
+		return prefixed
+	default:
+		return value
+	}
+}
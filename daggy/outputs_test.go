@@ -0,0 +1,197 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestWorkflow_RunOutputs(t *testing.T) {
+	var read string
+	plugins := map[string]testCommand{
+		"write": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+			output, _ := spec.Arguments["output"].(string)
+			return ioutil.WriteFile(output, []byte("hotfix"), 0600)
+		}},
+		"report": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+			input, _ := spec.Arguments["input"].(string)
+			b, err := ioutil.ReadFile(input)
+			read = string(b)
+			return err
+		}},
+	}
+
+	for _, keep := range []bool{false, true} {
+		workDir, err := ioutil.TempDir("", "outputstest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(workDir)
+
+		read = ""
+		workflow := Workflow{Tasks: map[string]Task{
+			"hotfixes": {
+				Command:   "write",
+				Arguments: map[string]interface{}{"output": "${outputs.csv}"},
+				Outputs:   map[string]string{"csv": "${name}.csv"},
+			},
+			"report": {
+				Command:   "report",
+				Arguments: map[string]interface{}{"input": "${tasks.hotfixes.outputs.csv}"},
+				Requires:  []string{"hotfixes"},
+			},
+		}, Vars: map[string]string{"name": "hotfixes"}, WorkDir: workDir, KeepWorkDir: keep, NoCache: true}
+		workflow.SetupGraph()
+
+		report, err := workflow.RunWithReport(context.Background(), "test.forensicstore", executors(plugins))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read != "hotfix" {
+			t.Errorf("RunWithReport() report read %q, want hotfix", read)
+		}
+
+		infos, err := ioutil.ReadDir(workDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keep {
+			output := filepath.Join(report.WorkDir, "hotfixes", "hotfixes.csv")
+			if len(infos) != 1 || report.Tasks[0].Outputs["csv"] != output {
+				t.Errorf("RunWithReport() outputs = %v, want kept %s", report.Tasks[0].Outputs, output)
+			}
+		} else if len(infos) != 0 {
+			t.Errorf("RunWithReport() working directory not removed")
+		}
+	}
+}
+
+func TestWorkflow_RunForeachOutputs(t *testing.T) {
+	var mux sync.Mutex
+	written := map[string]bool{}
+	plugins := map[string]testCommand{"write": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+		output, _ := spec.Arguments["output"].(string)
+		mux.Lock()
+		written[output] = true
+		mux.Unlock()
+		return ioutil.WriteFile(output, []byte(spec.Name), 0600)
+	}}}
+
+	workDir, err := ioutil.TempDir("", "outputstest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workDir)
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"per_user": {
+			Command:   "write",
+			Arguments: map[string]interface{}{"output": "${outputs.csv}"},
+			Outputs:   map[string]string{"csv": "${item}.csv"},
+			Foreach:   &Foreach{Items: []string{"alice", "bob", "../eve"}},
+		},
+	}, WorkDir: workDir, KeepWorkDir: true, NoCache: true}
+	workflow.SetupGraph()
+
+	report, err := workflow.RunWithReport(context.Background(), "test.forensicstore", executors(plugins))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		filepath.Join(report.WorkDir, "per_user[alice]", "alice.csv"):   true,
+		filepath.Join(report.WorkDir, "per_user[bob]", "bob.csv"):       true,
+		filepath.Join(report.WorkDir, "per_user[.._eve]", ".._eve.csv"): true,
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("RunWithReport() wrote %v, want %v", written, want)
+	}
+}
+
+func TestWorkflow_resolveOutputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		tasks   map[string]Task
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"own output", map[string]Task{
+			"test": {Arguments: map[string]interface{}{"output": "${outputs.csv}"}, Outputs: map[string]string{"csv": "out.csv"}},
+		}, map[string]interface{}{"output": filepath.Join("work", "test", "out.csv")}, false},
+		{"transitive requirement", map[string]Task{
+			"a":    {Outputs: map[string]string{"csv": "a.csv"}},
+			"b":    {Requires: []string{"a"}},
+			"test": {Arguments: map[string]interface{}{"input": "${tasks.a.outputs.csv}"}, Requires: []string{"b"}},
+		}, map[string]interface{}{"input": filepath.Join("work", "a", "a.csv")}, false},
+		{"workdir", map[string]Task{
+			"test": {Arguments: map[string]interface{}{"dir": "${workdir}"}},
+		}, map[string]interface{}{"dir": "work"}, false},
+		{"not required", map[string]Task{
+			"a":    {Outputs: map[string]string{"csv": "a.csv"}},
+			"test": {Arguments: map[string]interface{}{"input": "${tasks.a.outputs.csv}"}},
+		}, nil, true},
+		{"unknown output", map[string]Task{
+			"test": {Arguments: map[string]interface{}{"output": "${outputs.json}"}, Outputs: map[string]string{"csv": "out.csv"}},
+		}, nil, true},
+		{"foreach output", map[string]Task{
+			"a":    {Outputs: map[string]string{"csv": "a.csv"}, Foreach: &Foreach{Items: []string{"x"}}},
+			"test": {Arguments: map[string]interface{}{"input": "${tasks.a.outputs.csv}"}, Requires: []string{"a"}},
+		}, nil, true},
+		{"invalid reference", map[string]Task{
+			"a":    {},
+			"test": {Arguments: map[string]interface{}{"input": "${tasks.a}"}, Requires: []string{"a"}},
+		}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := &Workflow{Tasks: tt.tasks}
+			got, err := workflow.resolveTasks("work")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTasks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got["test"].Arguments, tt.want) {
+				t.Errorf("resolveTasks() got = %v, want %v", got["test"].Arguments, tt.want)
+			}
+		})
+	}
+}
+
+func Test_prefixReferences(t *testing.T) {
+	arguments := map[string]interface{}{
+		"input":  "${tasks.a.outputs.csv}",
+		"output": "${outputs.csv}",
+		"list":   []interface{}{"$${tasks.a.outputs.csv}"},
+	}
+	want := map[string]interface{}{
+		"input":  "${tasks.windows.a.outputs.csv}",
+		"output": "${outputs.csv}",
+		"list":   []interface{}{"$${tasks.a.outputs.csv}"},
+	}
+	if got := prefixReferences(arguments, "windows"); !reflect.DeepEqual(got, want) {
+		t.Errorf("prefixReferences() = %v, want %v", got, want)
+	}
+}
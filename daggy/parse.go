@@ -22,8 +22,15 @@
 package daggy
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/hashicorp/logutils"
 	"gopkg.in/yaml.v2"
@@ -31,24 +38,218 @@ import (
 
 // Parse reads a workflow file.
 func Parse(workflowFile string) (*Workflow, error) {
+	return ParseWithBuiltins(workflowFile, nil)
+}
+
+// ParseWithBuiltins reads a workflow file. Workflows in the include section
+// are either paths relative to the including workflow file or names of
+// builtin workflows. Included tasks are prefixed with the include name, e.g.
+// "windows.prefetch". Tasks can require single included tasks or whole
+// included workflows, e.g. "windows".
+func ParseWithBuiltins(workflowFile string, builtins map[string][]byte) (*Workflow, error) {
+	p := &parser{builtins: builtins}
+	workflow, _, err := p.parseFile(workflowFile, nil)
+	return workflow, err
+}
+
+type parser struct {
+	builtins map[string][]byte
+}
+
+// parseFile parses a workflow file and returns the workflow and the positions
+// of its keys, see yamlPositions.
+func (p *parser) parseFile(workflowFile string, stack []string) (*Workflow, map[string]Position, error) {
+	abs, err := filepath.Abs(workflowFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// parse the yaml definition
 	data, err := ioutil.ReadFile(workflowFile) // #nosec
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	return p.parse(data, abs, filepath.Dir(workflowFile), stack)
+}
+
+func (p *parser) parse(data []byte, id, dir string, stack []string) (*Workflow, map[string]Position, error) {
+	for i, parent := range stack {
+		if parent == id {
+			chain := append(append([]string{}, stack[i:]...), id)
+			return nil, nil, fmt.Errorf("circular include: %s", strings.Join(chain, " -> "))
+		}
 	}
+	stack = append(append([]string{}, stack...), id)
+
 	workflow := Workflow{}
-	err = yaml.Unmarshal(data, &workflow)
+	err := yaml.UnmarshalStrict(data, &workflow)
 	if err != nil {
-		return nil, err
+		return nil, nil, positionedError(id, err)
+	}
+	positions := yamlPositions(data, id)
+
+	var namespaces []string
+	for namespace := range workflow.Include {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		included, includedPositions, err := p.include(workflow.Include[namespace], dir, stack)
+		if err != nil {
+			return nil, nil, fmt.Errorf("include %s: %w", namespace, err)
+		}
+		err = workflow.merge(namespace, included)
+		if err != nil {
+			return nil, nil, fmt.Errorf("include %s: %w", namespace, err)
+		}
+		for path, position := range includedPositions {
+			if strings.HasPrefix(path, "tasks.") {
+				positions["tasks."+namespace+"."+strings.TrimPrefix(path, "tasks.")] = position
+			}
+		}
+	}
+	workflow.expandGroups()
+
+	return &workflow, positions, nil
+}
+
+// typeNames are replaced in yaml errors.
+var typeNames = strings.NewReplacer( // nolint: gochecknoglobals
+	"in type daggy.Workflow", "in workflow",
+	"in type daggy.Task", "in task",
+	"in type daggy.Condition", "in when",
+	"in type daggy.Foreach", "in foreach",
+	"in type daggy.failure", "in on_failure",
+)
+
+// positionedError prefixes yaml errors with the workflow file and line, e.g.
+// "workflow.yml:5: field require not found in task".
+func positionedError(file string, err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		if msg := strings.TrimPrefix(err.Error(), "yaml: line "); msg != err.Error() {
+			return fmt.Errorf("%s:%s", file, msg)
+		}
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	var messages []string
+	for _, message := range typeErr.Errors {
+		message = typeNames.Replace(message)
+		if strings.HasPrefix(message, "line ") {
+			message = strings.TrimPrefix(message, "line ")
+			messages = append(messages, file+":"+message)
+		} else {
+			messages = append(messages, file+": "+message)
+		}
+	}
+	return errors.New(strings.Join(messages, "\n"))
+}
+
+// include parses a workflow given as path or as name of a builtin workflow.
+func (p *parser) include(ref, dir string, stack []string) (*Workflow, map[string]Position, error) {
+	if data, ok := p.builtins[ref]; ok {
+		return p.parse(data, "builtin:"+ref, dir, stack)
+	}
+	if !filepath.IsAbs(ref) {
+		ref = filepath.Join(dir, ref)
+	}
+	if _, err := os.Stat(ref); err != nil {
+		return nil, nil, fmt.Errorf("workflow %s not found", ref)
+	}
+	return p.parseFile(ref, stack)
+}
+
+// merge adds the tasks of an included workflow with the namespace as prefix.
+// Variables and classes of the included workflow are used as defaults.
+func (workflow *Workflow) merge(namespace string, included *Workflow) error {
+	if workflow.Tasks == nil {
+		workflow.Tasks = map[string]Task{}
 	}
-	return &workflow, nil
+	for name, task := range included.Tasks {
+		// hooks of included workflows are ignored
+		if included.isHook(name) {
+			continue
+		}
+		prefixed := namespace + "." + name
+		if _, ok := workflow.Tasks[prefixed]; ok {
+			return fmt.Errorf("task %s already exists", prefixed)
+		}
+		var requires []string
+		for _, requirement := range task.Requires {
+			requires = append(requires, namespace+"."+requirement)
+		}
+		task.Requires = requires
+		if task.Arguments != nil {
+			task.Arguments, _ = prefixReferences(task.Arguments, namespace).(map[string]interface{})
+		}
+		workflow.Tasks[prefixed] = task
+	}
+
+	for name, value := range included.Vars {
+		if _, ok := workflow.Vars[name]; !ok {
+			if workflow.Vars == nil {
+				workflow.Vars = map[string]string{}
+			}
+			workflow.Vars[name] = value
+		}
+	}
+	for class, limit := range included.Classes {
+		if _, ok := workflow.Classes[class]; !ok {
+			if workflow.Classes == nil {
+				workflow.Classes = map[string]int{}
+			}
+			workflow.Classes[class] = limit
+		}
+	}
+	return nil
 }
 
+// expandGroups replaces requirements on included workflows with all tasks of
+// the included workflow.
+func (workflow *Workflow) expandGroups() {
+	for name, task := range workflow.Tasks {
+		var requires []string
+		for _, requirement := range task.Requires {
+			if _, ok := workflow.Tasks[requirement]; ok {
+				requires = append(requires, requirement)
+				continue
+			}
+			group := workflow.group(requirement)
+			if len(group) == 0 {
+				requires = append(requires, requirement)
+				continue
+			}
+			requires = append(requires, group...)
+		}
+		task.Requires = requires
+		workflow.Tasks[name] = task
+	}
+}
+
+func (workflow *Workflow) group(namespace string) []string {
+	var tasks []string
+	for name := range workflow.Tasks {
+		if strings.HasPrefix(name, namespace+".") {
+			tasks = append(tasks, name)
+		}
+	}
+	sort.Strings(tasks)
+	return tasks
+}
+
+// setupLoggingOnce makes sure the log filter is added only once, as every
+// filter would wrap the previous one.
+var setupLoggingOnce sync.Once // nolint: gochecknoglobals
+
 func setupLogging() {
-	// disable logging in github.com/hashicorp/terraform/dag
-	log.SetOutput(&logutils.LevelFilter{
-		Levels:   []logutils.LogLevel{"TRACE", "OTHER"},
-		MinLevel: "OTHER",
-		Writer:   log.Writer(),
+	setupLoggingOnce.Do(func() {
+		// disable logging in github.com/hashicorp/terraform/dag
+		log.SetOutput(&logutils.LevelFilter{
+			Levels:   []logutils.LogLevel{"TRACE", "OTHER"},
+			MinLevel: "OTHER",
+			Writer:   log.Writer(),
+		})
 	})
 }
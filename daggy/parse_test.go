@@ -22,8 +22,12 @@
 package daggy
 
 import (
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -89,7 +93,93 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseWithBuiltins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daggyparsetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"main.yml": `
+include:
+  windows: windows.yml
+  linux: linux
+tasks:
+  report:
+    command: report
+    requires: [windows, linux.files]
+`,
+		"windows.yml": `
+vars:
+  output_dir: /windows
+tasks:
+  prefetch:
+    command: prefetch
+  prefetch_report:
+    command: report
+    requires: [prefetch]
+`,
+		"a.yml":       "include: {b: b.yml}",
+		"b.yml":       "include: {a: a.yml}",
+		"missing.yml": "include: {x: x.yml}",
+		"unknown.yml": "tasks:\n  a:\n    command: x\n    require: [b]\n",
+		"invalid.yml": "tasks:\n  a: [\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	builtins := map[string][]byte{"linux": []byte("tasks: {files: {command: export}}")}
+
+	want := map[string]Task{
+		"report":                  {Command: "report", Requires: []string{"windows.prefetch", "windows.prefetch_report", "linux.files"}},
+		"windows.prefetch":        {Command: "prefetch"},
+		"windows.prefetch_report": {Command: "report", Requires: []string{"windows.prefetch"}},
+		"linux.files":             {Command: "export"},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		want    map[string]Task
+		wantErr string
+	}{
+		{"include", "main.yml", want, ""},
+		{"circular include", "a.yml", nil, "circular include"},
+		{"missing include", "missing.yml", nil, "not found"},
+		{"unknown field", "unknown.yml", nil, "unknown.yml:4: field require not found in task"},
+		{"invalid yaml", "invalid.yml", nil, "invalid.yml:2: did not find expected node content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWithBuiltins(filepath.Join(dir, tt.file), builtins)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseWithBuiltins() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Tasks, tt.want) {
+				t.Errorf("ParseWithBuiltins() got = %#v, want %#v", got.Tasks, tt.want)
+			}
+			if got.Vars["output_dir"] != "/windows" {
+				t.Errorf("ParseWithBuiltins() vars = %v, want included vars", got.Vars)
+			}
+		})
+	}
+}
+
 func Test_setupLogging(t *testing.T) {
 	setupLogging()
+	writer := log.Writer()
+	setupLogging()
+	if log.Writer() != writer {
+		t.Error("setupLogging() added another log filter")
+	}
 	log.Print("test")
 }
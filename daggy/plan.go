@@ -0,0 +1,131 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Step is a task in an execution plan.
+type Step struct {
+	Name    string
+	Command string
+	// Args are the resolved command line arguments without the
+	// forensicstore.
+	Args     []string
+	Requires []string
+	When     *Condition
+	Foreach  *Foreach
+}
+
+// Plan returns the tasks of the workflow in waves with resolved arguments.
+// Tasks of a wave only require tasks of previous waves, so they can run in
+// parallel. Plan does not access the forensicstore.
+func (workflow *Workflow) Plan() ([][]Step, error) {
+	if err := workflow.Validate(); err != nil {
+		return nil, err
+	}
+	tasks, err := workflow.resolveTasks("<workdir>")
+	if err != nil {
+		return nil, err
+	}
+
+	waves := map[string]int{}
+	var wave func(name string, path []string) (int, error)
+	wave = func(name string, path []string) (int, error) {
+		if w, ok := waves[name]; ok {
+			return w, nil
+		}
+		for _, parent := range path {
+			if parent == name {
+				return 0, fmt.Errorf("cycle between tasks: %s", strings.Join(append(path, name), " -> "))
+			}
+		}
+		task, ok := tasks[name]
+		if !ok {
+			return 0, fmt.Errorf("task %s requires unknown task %s", path[len(path)-1], name)
+		}
+		w := 0
+		for _, requirement := range task.Requires {
+			requirementWave, err := wave(requirement, append(path, name))
+			if err != nil {
+				return 0, err
+			}
+			if requirementWave+1 > w {
+				w = requirementWave + 1
+			}
+		}
+		waves[name] = w
+		return w, nil
+	}
+
+	var names []string
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var plan [][]Step
+	for _, name := range names {
+		w, err := wave(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		for len(plan) <= w {
+			plan = append(plan, nil)
+		}
+		task := tasks[name]
+		plan[w] = append(plan[w], Step{
+			Name:     name,
+			Command:  task.Command,
+			Args:     task.cmdline(),
+			Requires: task.Requires,
+			When:     task.When,
+			Foreach:  task.Foreach,
+		})
+	}
+	return plan, nil
+}
+
+// PlanHooks returns the hooks of the workflow by hook point with resolved
+// arguments. Hooks that do not exist are left out, see Validate.
+func (workflow *Workflow) PlanHooks() (map[string][]Step, error) {
+	plan := map[string][]Step{}
+	for point, hooks := range workflow.hooks() {
+		reportFile := filepath.Join("<workdir>", point+".report.json")
+		for _, name := range hooks {
+			task, ok := workflow.Tasks[name]
+			if !ok {
+				continue
+			}
+			task, err := resolveTask(task, workflow.lookupHook("<workdir>", reportFile))
+			if err != nil {
+				return nil, fmt.Errorf("hook %s: %w", name, err)
+			}
+			plan[point] = append(plan[point], Step{Name: name, Command: task.Command, Args: task.cmdline()})
+		}
+	}
+	return plan, nil
+}
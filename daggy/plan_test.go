@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkflow_Plan(t *testing.T) {
+	tests := []struct {
+		name     string
+		workflow Workflow
+		want     [][]string
+		wantArgs []string
+		wantErr  bool
+	}{
+		{"waves", Workflow{Tasks: map[string]Task{
+			"a": {Command: "x", Arguments: map[string]interface{}{"output": "${dir}/a.txt"}},
+			"b": {Command: "x"},
+			"c": {Command: "x", Requires: []string{"a"}},
+			"d": {Command: "x", Requires: []string{"b", "c"}},
+		}, Vars: map[string]string{"dir": "out"}}, [][]string{{"a", "b"}, {"c"}, {"d"}}, []string{"--output", "out/a.txt"}, false},
+		{"unknown requirement", Workflow{Tasks: map[string]Task{
+			"a": {Command: "x", Requires: []string{"b"}},
+		}}, nil, nil, true},
+		{"cycle", Workflow{Tasks: map[string]Task{
+			"a": {Command: "x", Requires: []string{"b"}},
+			"b": {Command: "x", Requires: []string{"a"}},
+		}}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := tt.workflow.Plan()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Plan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got [][]string
+			for _, wave := range plan {
+				var names []string
+				for _, step := range wave {
+					names = append(names, step.Name)
+				}
+				got = append(got, names)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plan() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantArgs != nil && !reflect.DeepEqual(plan[0][0].Args, tt.wantArgs) {
+				t.Errorf("Plan() args = %v, want %v", plan[0][0].Args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+// A Report describes a workflow run.
+type Report struct {
+	Run       string    `json:"run"`
+	Workflow  string    `json:"workflow"`
+	Store     string    `json:"store"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// Duration is given in seconds.
+	Duration      float64 `json:"duration"`
+	ElementsAdded int64   `json:"elements_added"`
+	// WorkDir is the working directory of the run, if it was kept.
+	WorkDir string        `json:"workdir,omitempty"`
+	Tasks   []*TaskReport `json:"tasks"`
+	// Hooks are the hooks that ran, named <point>.<name>.
+	Hooks []*TaskReport `json:"hooks,omitempty"`
+}
+
+// A TaskReport describes the execution of a single task.
+type TaskReport struct {
+	Name      string    `json:"name"`
+	Command   string    `json:"command"`
+	Arguments []string  `json:"arguments,omitempty"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// Duration is given in seconds.
+	Duration float64 `json:"duration"`
+	Attempts int     `json:"attempts"`
+	// ElementsAdded is the number of elements the task inserted into the
+	// forensicstore.
+	ElementsAdded int64 `json:"elements_added"`
+	// Outputs are the paths of the declared outputs of the task.
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// Tasks are the sub-tasks of a foreach task.
+	Tasks []*TaskReport `json:"tasks,omitempty"`
+
+	parent string
+	hook   bool
+}
+
+// WriteFile writes the report as JSON file.
+func (report *Report) WriteFile(path string) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644) // #nosec
+}
+
+// taskReport returns the report of a task, statusMux must be held.
+func (r *run) taskReport(name string) *TaskReport {
+	if _, ok := r.reports[name]; !ok {
+		r.reports[name] = &TaskReport{Name: name}
+	}
+	return r.reports[name]
+}
+
+// addAttempt counts an attempt of a task and the elements it added.
+func (r *run) addAttempt(name string, elementsAdded int64) {
+	r.statusMux.Lock()
+	defer r.statusMux.Unlock()
+	report := r.taskReport(name)
+	report.Attempts++
+	report.ElementsAdded += elementsAdded
+}
+
+// countElements returns the number of elements in the forensicstore for
+// tasks that write to it. The store lock must be held.
+func (r *run) countElements(info CommandInfo, task Task) int64 {
+	if !writesStore(info, task) {
+		return 0
+	}
+
+	store, teardown, err := forensicstore.Open(r.storeDir)
+	if err != nil {
+		log.Printf("could not count elements: %s", err)
+		return 0
+	}
+	defer teardown()
+
+	count, err := queryInt(store.Connection(), "SELECT count(*) FROM elements", nil)
+	if err != nil {
+		log.Printf("could not count elements: %s", err)
+	}
+	return count
+}
+
+// report assembles the report of the run, sub-tasks are grouped under their
+// foreach task and hooks are listed separately.
+func (r *run) report(start time.Time, runErr error) *Report {
+	end := time.Now()
+	report := &Report{
+		Run:       r.id,
+		Workflow:  r.hash,
+		Store:     r.storeDir,
+		Status:    StatusSucceeded,
+		StartTime: start.UTC(),
+		EndTime:   end.UTC(),
+		Duration:  end.Sub(start).Seconds(),
+		Tasks:     []*TaskReport{},
+	}
+	if r.workflow.KeepWorkDir {
+		report.WorkDir = r.workdir
+	}
+	if runErr != nil {
+		report.Status = StatusFailed
+		report.Error = runErr.Error()
+		var failures *RunError
+		if errors.As(runErr, &failures) && failures.ExitCode() == ExitOptionalFailures {
+			report.Status = StatusSucceeded
+		}
+	}
+
+	r.statusMux.Lock()
+	defer r.statusMux.Unlock()
+
+	var names []string
+	for name := range r.reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// the report is assembled again for hooks
+		r.reports[name].Tasks = nil
+	}
+	for _, name := range names {
+		taskReport := r.reports[name]
+		if taskReport.hook {
+			report.Hooks = append(report.Hooks, taskReport)
+			continue
+		}
+		if taskReport.parent != "" {
+			parent := r.taskReport(taskReport.parent)
+			parent.Tasks = append(parent.Tasks, taskReport)
+			continue
+		}
+		report.ElementsAdded += taskReport.ElementsAdded
+		report.Tasks = append(report.Tasks, taskReport)
+	}
+	return report
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+func TestWorkflow_RunWithReport(t *testing.T) {
+	storeDir, cleanupStore := newTestStore(t)
+	defer cleanupStore()
+
+	failures := 1
+	plugins := map[string]testCommand{
+		"insert": {
+			run: func(ctx context.Context, store string, spec TaskSpec) error {
+				s, teardown, err := forensicstore.Open(store)
+				if err != nil {
+					return err
+				}
+				defer teardown()
+				for i := 0; i < 2; i++ {
+					if _, err := s.Insert(forensicstore.JSONElement(`{"type": "test"}`)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			info: CommandInfo{WritesStore: true},
+		},
+		"flaky": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+			if failures > 0 {
+				failures--
+				return errors.New("flaky")
+			}
+			return nil
+		}},
+	}
+
+	workflow := Workflow{Tasks: map[string]Task{
+		"insert":  {Command: "insert"},
+		"flaky":   {Command: "flaky", Retries: 1, Requires: []string{"insert"}},
+		"foreach": {Command: "insert", Foreach: &Foreach{Items: []string{"a", "b"}}},
+	}, Parallelism: 1, NoCache: true}
+	workflow.SetupGraph()
+
+	report, err := workflow.RunWithReport(context.Background(), storeDir, executors(plugins))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != StatusSucceeded || report.ElementsAdded != 6 || len(report.Tasks) != 3 {
+		t.Errorf("RunWithReport() status = %s, elements = %d, tasks = %d", report.Status, report.ElementsAdded, len(report.Tasks))
+	}
+	tasks := map[string]*TaskReport{}
+	for _, task := range report.Tasks {
+		tasks[task.Name] = task
+	}
+	if tasks["flaky"].Attempts != 2 || tasks["flaky"].ElementsAdded != 0 {
+		t.Errorf("RunWithReport() flaky attempts = %d, elements = %d", tasks["flaky"].Attempts, tasks["flaky"].ElementsAdded)
+	}
+	if tasks["insert"].Attempts != 1 || tasks["insert"].ElementsAdded != 2 {
+		t.Errorf("RunWithReport() insert attempts = %d, elements = %d", tasks["insert"].Attempts, tasks["insert"].ElementsAdded)
+	}
+	if len(tasks["foreach"].Tasks) != 2 || tasks["foreach"].ElementsAdded != 4 || tasks["foreach"].Tasks[0].Name != "foreach[a]" {
+		t.Errorf("RunWithReport() foreach = %+v", tasks["foreach"])
+	}
+
+	reportFile := filepath.Join(filepath.Dir(storeDir), "report.json")
+	if err := report.WriteFile(reportFile); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := Report{}
+	if err := json.Unmarshal(b, &written); err != nil {
+		t.Fatal(err)
+	}
+	if written.Run != report.Run || len(written.Tasks) != 3 {
+		t.Errorf("WriteFile() wrote %s", b)
+	}
+}
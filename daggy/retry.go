@@ -0,0 +1,99 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// exitCoder is implemented by errors of plugins that return an exit code,
+// e.g. *exec.ExitError.
+type exitCoder interface {
+	ExitCode() int
+}
+
+// runAttempts runs a task until it succeeds or all retries are exhausted.
+func (r *run) runAttempts(ctx context.Context, executor Executor, name string, task Task) error {
+	ctx = r.withTask(ctx, name)
+	info := describe(executor)
+	var err error
+	attempts := task.Retries + 1
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("retry task %s in %s", name, task.RetryDelay)
+			select {
+			case <-time.After(time.Duration(task.RetryDelay)):
+			case <-ctx.Done():
+				return &InterruptedError{Cause: ctx.Err(), Timeout: task.Timeout, Err: err}
+			}
+		}
+
+		release, acquireErr := r.limits.acquire(ctx, info, task)
+		if acquireErr != nil {
+			return &InterruptedError{Cause: acquireErr, Timeout: task.Timeout, Err: err}
+		}
+		r.emit(Event{Type: TaskStarted, Task: name, Attempt: attempt})
+		before := r.countElements(info, task)
+		var result Result
+		result, err = r.workflow.runTask(ctx, executor, name, task, r.storeDir)
+		r.addAttempt(name, r.countElements(info, task)-before)
+		release()
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("task %s attempt %d/%d failed: %s", name, attempt, attempts, err)
+		if ctx.Err() != nil || !retryable(result, err, task.RetryOn) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryable checks if a failed task can be retried. Without exit codes any
+// error is retryable, otherwise only results or errors with one of the exit
+// codes.
+func retryable(result Result, err error, exitCodes []int) bool {
+	if len(exitCodes) == 0 {
+		return true
+	}
+	code := result.ExitCode
+	var coder exitCoder
+	if code == 0 && errors.As(err, &coder) {
+		code = coder.ExitCode()
+	}
+	if code == 0 {
+		return false
+	}
+	for _, exitCode := range exitCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", e) }
+func (e exitError) ExitCode() int { return int(e) }
+
+func Test_retryable(t *testing.T) {
+	type args struct {
+		result    Result
+		err       error
+		exitCodes []int
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"any error", args{Result{}, errors.New("failed"), nil}, true},
+		{"no exit code", args{Result{}, errors.New("failed"), []int{1}}, false},
+		{"matching exit code", args{Result{}, exitError(2), []int{1, 2}}, true},
+		{"wrapped exit code", args{Result{}, fmt.Errorf("script failed with %w", exitError(2)), []int{2}}, true},
+		{"other exit code", args{Result{}, exitError(3), []int{1, 2}}, false},
+		{"result exit code", args{Result{ExitCode: 2}, errors.New("failed"), []int{2}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.args.result, tt.args.err, tt.args.exitCodes); got != tt.want {
+				t.Errorf("retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		task         Task
+		failures     int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"no retries", Task{Command: "flaky"}, 1, 1, true},
+		{"success after retry", Task{Command: "flaky", Retries: 2}, 2, 3, false},
+		{"retries exhausted", Task{Command: "flaky", Retries: 1}, 2, 2, true},
+		{"retry on exit code", Task{Command: "flaky", Retries: 1, RetryOn: []int{1}}, 1, 2, false},
+		{"no retry on exit code", Task{Command: "flaky", Retries: 1, RetryOn: []int{2}}, 1, 1, true},
+		{"negative retries", Task{Command: "flaky", Retries: -1}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			plugins := map[string]testCommand{"flaky": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+				attempts++
+				if attempts <= tt.failures {
+					return exitError(1)
+				}
+				return nil
+			}}}
+
+			workflow := Workflow{Tasks: map[string]Task{"test": tt.task}}
+			workflow.SetupGraph()
+
+			err := workflow.Run(context.Background(), "test.forensicstore", executors(plugins))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("Run() attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestTask_RetryDelayYAML(t *testing.T) {
+	task := Task{}
+	if err := yaml.UnmarshalStrict([]byte("retry_delay: 5s"), &task); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(task.RetryDelay) != 5*time.Second {
+		t.Errorf("retry_delay = %s, want 5s", task.RetryDelay)
+	}
+	// a number without unit would be a delay of nanoseconds
+	if err := yaml.UnmarshalStrict([]byte("retry_delay: 5"), &Task{}); err == nil {
+		t.Error("retry_delay without unit expected error")
+	}
+}
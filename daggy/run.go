@@ -0,0 +1,210 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/tfdiags"
+)
+
+// Status is the state of a task after a workflow run.
+type Status string
+
+// Task states.
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+	StatusCached    Status = "cached"
+	// StatusCancelled is used for tasks that were cancelled before they
+	// finished or before they could start.
+	StatusCancelled Status = "cancelled"
+	// StatusTimedOut is used for tasks that exceeded their timeout.
+	StatusTimedOut Status = "timed_out"
+	// StatusRunning is only used in the reports for on_start hooks.
+	StatusRunning Status = "running"
+)
+
+// failed checks if the task did not succeed because it failed, was
+// cancelled or timed out.
+func (s Status) failed() bool {
+	return s == StatusFailed || s == StatusCancelled || s == StatusTimedOut
+}
+
+// failedStatus returns the status of a task that returned the error.
+func failedStatus(err error) Status {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return StatusTimedOut
+	case errors.Is(err, context.Canceled):
+		return StatusCancelled
+	}
+	return StatusFailed
+}
+
+// run holds the state of a single workflow run.
+type run struct {
+	ctx context.Context
+	// taskCtx is cancelled with ctx or once the run is stopped.
+	taskCtx     context.Context
+	cancelTasks context.CancelFunc
+	id          string
+	workflow    *Workflow
+	// hash is the hash of the workflow, computed once per run.
+	hash      string
+	tasks     map[string]Task
+	storeDir  string
+	workdir   string
+	executors map[string]Executor
+	limits    *limits
+
+	statuses  map[string]Status
+	reports   map[string]*TaskReport
+	stopped   bool
+	statusMux sync.Mutex
+}
+
+func (r *run) status(name string) Status {
+	r.statusMux.Lock()
+	defer r.statusMux.Unlock()
+	return r.statuses[name]
+}
+
+func (r *run) setStatus(name string, status Status) {
+	r.statusMux.Lock()
+	defer r.statusMux.Unlock()
+	r.statuses[name] = status
+}
+
+// visit is called by the dag walker for every task. Failed tasks are
+// collected by runError, so the walker always continues.
+func (r *run) visit(name string) tfdiags.Diagnostics {
+	task := r.tasks[name]
+	r.emit(Event{Type: TaskQueued, Task: name})
+
+	start := time.Now()
+	status, err := r.execute(name, task)
+	r.setStatus(name, status)
+
+	// stop before recording, recording waits for running tasks that write
+	// to the store
+	if err != nil {
+		if task.AllowFailure {
+			log.Printf("task %s failed, failure allowed: %s", name, err)
+		} else {
+			log.Printf("task %s failed: %s", name, err)
+			if r.workflow.OnFailure.Policy == OnFailureStop {
+				r.stop()
+			}
+		}
+	}
+	r.recordTask(name, "", task, status, start, err)
+	return nil
+}
+
+// stop cancels all running tasks and prevents new tasks from being started.
+func (r *run) stop() {
+	r.statusMux.Lock()
+	r.stopped = true
+	r.statusMux.Unlock()
+	r.cancelTasks()
+}
+
+func (r *run) isStopped() bool {
+	r.statusMux.Lock()
+	defer r.statusMux.Unlock()
+	return r.stopped
+}
+
+func (r *run) execute(name string, task Task) (Status, error) {
+	if r.isStopped() {
+		log.Printf("skip task %s: workflow stopped after failure", name)
+		return StatusSkipped, nil
+	}
+
+	// tasks that depend on skipped or failed tasks are skipped
+	for _, requirement := range task.Requires {
+		if status := r.status(requirement); status == StatusSkipped || status.failed() {
+			log.Printf("skip task %s: requirement %s was %s", name, requirement, r.status(requirement))
+			return StatusSkipped, nil
+		}
+	}
+
+	executor, ok := r.executors[task.Command]
+	if !ok {
+		return StatusFailed, fmt.Errorf("command %s not found", task.Command)
+	}
+
+	if task.When != nil {
+		r.limits.store.RLock()
+		ok, err := task.When.Check(r.storeDir)
+		r.limits.store.RUnlock()
+		if err != nil {
+			return StatusFailed, fmt.Errorf("could not check condition: %w", err)
+		}
+		if !ok {
+			log.Printf("skip task %s: condition not met", name)
+			return StatusSkipped, nil
+		}
+	}
+
+	if task.Foreach != nil {
+		return r.fanOut(name, executor, task)
+	}
+	return r.perform(name, executor, task)
+}
+
+// perform runs a task unless it already succeeded or its inputs are
+// unchanged.
+func (r *run) perform(name string, executor Executor, task Task) (Status, error) {
+	if r.workflow.Resume && r.succeededBefore(name, task) {
+		log.Printf("skip task %s: already succeeded", name)
+		return StatusSucceeded, nil
+	}
+
+	var fingerprint string
+	if info := describe(executor); !r.workflow.NoCache && cacheable(info, task) {
+		var err error
+		fingerprint, err = r.fingerprint(info, task)
+		if err != nil {
+			log.Printf("could not fingerprint task %s: %s", name, err)
+		} else if r.cached(fingerprint) {
+			log.Printf("skip task %s: inputs unchanged", name)
+			return StatusCached, nil
+		}
+	}
+
+	if err := r.runAttempts(r.taskCtx, executor, name, task); err != nil {
+		return failedStatus(err), err
+	}
+
+	if fingerprint != "" {
+		r.cache(fingerprint, name)
+	}
+	return StatusSucceeded, nil
+}
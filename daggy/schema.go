@@ -0,0 +1,140 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+// Schema is the JSON Schema of workflow files. Its descriptions are not
+// wrapped, as they are part of the JSON document.
+// nolint: lll
+const Schema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/forensicanalysis/forensicworkflows/workflow.schema.json",
+  "title": "forensicworkflows workflow",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "tasks": {
+      "description": "Tasks by name.",
+      "type": "object",
+      "additionalProperties": {"$ref": "#/definitions/task"}
+    },
+    "include": {
+      "description": "Workflows whose tasks are added with the key as prefix, given as path relative to this file or name of a builtin workflow.",
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    },
+    "vars": {
+      "description": "Variables that can be referenced as ${name} in task arguments.",
+      "type": "object",
+      "additionalProperties": {"type": ["string", "number", "boolean"]}
+    },
+    "parallelism": {
+      "description": "Maximum number of parallel tasks, defaults to the number of CPUs.",
+      "type": "integer",
+      "minimum": 0
+    },
+    "classes": {
+      "description": "Maximum number of parallel tasks per concurrency class.",
+      "type": "object",
+      "additionalProperties": {"type": "integer", "minimum": 1}
+    },
+    "on_start": {"description": "Hooks that run before the first task.", "$ref": "#/definitions/hooks"},
+    "on_success": {"description": "Hooks that run if no required task failed.", "$ref": "#/definitions/hooks"},
+    "on_failure": {
+      "description": "Failure policy, hooks that run if a required task failed, or both.",
+      "oneOf": [
+        {"$ref": "#/definitions/policy"},
+        {"$ref": "#/definitions/hooks"},
+        {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "policy": {"$ref": "#/definitions/policy"},
+            "hooks": {"$ref": "#/definitions/hooks"}
+          }
+        }
+      ]
+    },
+    "finally": {"description": "Hooks that run at the end of every run.", "$ref": "#/definitions/hooks"}
+  },
+  "definitions": {
+    "hooks": {
+      "description": "Names of tasks that run outside of the graph, the path of the run report is available to them as ${report}.",
+      "type": "array",
+      "items": {"type": "string"}
+    },
+    "policy": {
+      "description": "Run all tasks that do not depend on failed tasks (continue) or cancel running tasks and start no new tasks after a failure (stop).",
+      "enum": ["continue", "stop"]
+    },
+    "duration": {
+      "description": "Duration with unit like 90s, 5m or 1h30m, numbers without unit are invalid.",
+      "type": "string",
+      "pattern": "^(0|([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$"
+    },
+    "filter": {
+      "description": "Elements that match any of the conditions, a condition matches if all attributes match.",
+      "type": "array",
+      "items": {"type": "object", "additionalProperties": {"type": "string"}}
+    },
+    "task": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["command"],
+      "properties": {
+        "command": {"description": "Name of the command that is run.", "type": "string"},
+        "arguments": {"description": "Arguments of the command by flag name.", "type": "object"},
+        "requires": {"description": "Tasks or included workflows that must run before.", "type": "array", "items": {"type": "string"}},
+        "class": {"description": "Concurrency class of the task.", "type": "string"},
+        "timeout": {"$ref": "#/definitions/duration"},
+        "retries": {"description": "Number of additional attempts.", "type": "integer", "minimum": 0},
+        "retry_delay": {"$ref": "#/definitions/duration"},
+        "retry_on": {"description": "Exit codes that are retried.", "type": "array", "items": {"type": "integer"}},
+        "when": {
+          "description": "Run the task only if the number of matching elements meets the count.",
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "filter": {"$ref": "#/definitions/filter"},
+            "count": {"description": "Comparison like \">= 1\" or \"== 0\".", "type": "string", "pattern": "^\\s*(>=|>|<=|<|==|!=)?\\s*[0-9]+\\s*$"}
+          }
+        },
+        "foreach": {
+          "description": "Run the task once per item, referenced as ${item}.",
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "items": {"type": "array", "items": {"type": "string"}},
+            "filter": {"$ref": "#/definitions/filter"},
+            "field": {"description": "Field of the matching elements whose distinct values are used as items.", "type": "string"}
+          }
+        },
+        "outputs": {
+          "description": "Files written by the task by key, referenced as ${outputs.<key>}. Foreach tasks write them per item and can use ${item} in the file name.",
+          "type": "object",
+          "additionalProperties": {"type": "string"}
+        },
+        "allow_failure": {"description": "A failure of the task does not fail the workflow.", "type": "boolean"}
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,101 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchema(t *testing.T) {
+	type property struct {
+		Properties map[string]property `json:"properties"`
+		OneOf      []property          `json:"oneOf"`
+	}
+	schema := struct {
+		property
+		Definitions map[string]property `json:"definitions"`
+	}{}
+	if err := json.Unmarshal([]byte(Schema), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	// every yaml field must be described in the schema
+	tests := []struct {
+		name       string
+		value      interface{}
+		properties map[string]property
+	}{
+		{"workflow", Workflow{}, schema.Properties},
+		{"task", Task{}, schema.Definitions["task"].Properties},
+		{"when", Condition{}, schema.Definitions["task"].Properties["when"].Properties},
+		{"foreach", Foreach{}, schema.Definitions["task"].Properties["foreach"].Properties},
+		{"on_failure", Failure{}, schema.Properties["on_failure"].OneOf[2].Properties},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ := reflect.TypeOf(tt.value)
+			for i := 0; i < typ.NumField(); i++ {
+				tag := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+				if tag == "" || tag == "-" {
+					continue
+				}
+				if _, ok := tt.properties[tag]; !ok {
+					t.Errorf("Schema is missing %s.%s", tt.name, tag)
+				}
+			}
+			if len(tt.properties) == 0 {
+				t.Errorf("Schema has no properties for %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestSchema_duration(t *testing.T) {
+	schema := struct {
+		Definitions map[string]struct {
+			Type    string `json:"type"`
+			Pattern string `json:"pattern"`
+		} `json:"definitions"`
+	}{}
+	if err := json.Unmarshal([]byte(Schema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	duration := schema.Definitions["duration"]
+	if duration.Type != "string" {
+		t.Fatalf("Schema duration type = %q, want string", duration.Type)
+	}
+	pattern := regexp.MustCompile(duration.Pattern)
+
+	// the schema accepts the same durations as Duration
+	for _, value := range []string{"0", "90s", "1h30m", "1.5h", "500ms", "10", "5 s", "soon"} {
+		_, err := time.ParseDuration(value)
+		valid := err == nil
+		if pattern.MatchString(value) != valid {
+			t.Errorf("Schema duration matches %q = %v, want %v", value, pattern.MatchString(value), valid)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+)
+
+// Select reduces the workflow to the target tasks and all tasks they
+// require, directly or transitively. Skipped tasks and all tasks that
+// require them are removed. Without targets all tasks are selected. Targets
+// and skipped tasks can also name included workflows. Hooks are kept.
+func (workflow *Workflow) Select(targets, skip []string) error {
+	if workflow.graph == nil {
+		workflow.SetupGraph()
+	}
+
+	selected := map[string]bool{}
+	for name := range workflow.Tasks {
+		if len(targets) == 0 || workflow.isHook(name) {
+			selected[name] = true
+		}
+	}
+	for _, target := range targets {
+		names, err := workflow.taskNames(target)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			selected[name] = true
+			requirements, err := workflow.graph.Descendents(name)
+			if err != nil {
+				return err
+			}
+			for _, requirement := range requirements.List() {
+				selected[requirement.(string)] = true
+			}
+		}
+	}
+
+	for _, skipped := range skip {
+		names, err := workflow.taskNames(skipped)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			delete(selected, name)
+			dependents, err := workflow.graph.Ancestors(name)
+			if err != nil {
+				return err
+			}
+			for _, dependent := range dependents.List() {
+				delete(selected, dependent.(string))
+			}
+		}
+	}
+
+	if workflow.hash == "" {
+		workflow.hash = workflow.Hash()
+	}
+	tasks := map[string]Task{}
+	for name := range selected {
+		tasks[name] = workflow.Tasks[name]
+	}
+	workflow.Tasks = tasks
+	workflow.SetupGraph()
+	return nil
+}
+
+// taskNames returns the task or all tasks of the included workflow with the
+// name.
+func (workflow *Workflow) taskNames(name string) ([]string, error) {
+	if _, ok := workflow.Tasks[name]; ok && !workflow.isHook(name) {
+		return []string{name}, nil
+	}
+	if group := workflow.group(name); len(group) > 0 {
+		return group, nil
+	}
+	return nil, fmt.Errorf("unknown task %s", name)
+}
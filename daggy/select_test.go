@@ -0,0 +1,85 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestWorkflow_Select(t *testing.T) {
+	tasks := map[string]Task{
+		"prefetch":        {Command: "prefetch"},
+		"prefetch_report": {Command: "report", Requires: []string{"prefetch"}},
+		"eventlogs":       {Command: "eventlogs"},
+		"plaso":           {Command: "plaso"},
+		"timeline":        {Command: "timeline", Requires: []string{"plaso", "eventlogs"}},
+		"windows.usb":     {Command: "usb"},
+		"windows.network": {Command: "networking"},
+	}
+
+	tests := []struct {
+		name    string
+		targets []string
+		skip    []string
+		want    []string
+		wantErr bool
+	}{
+		{"all", nil, nil, []string{"eventlogs", "plaso", "prefetch", "prefetch_report", "timeline", "windows.network", "windows.usb"}, false},
+		{"target with requirements", []string{"prefetch_report"}, nil, []string{"prefetch", "prefetch_report"}, false},
+		{"multiple targets", []string{"prefetch", "timeline"}, nil, []string{"eventlogs", "plaso", "prefetch", "timeline"}, false},
+		{"target group", []string{"windows"}, nil, []string{"windows.network", "windows.usb"}, false},
+		{"skip with dependents", nil, []string{"plaso"}, []string{"eventlogs", "prefetch", "prefetch_report", "windows.network", "windows.usb"}, false},
+		{"target and skip", []string{"timeline", "prefetch_report"}, []string{"plaso"}, []string{"eventlogs", "prefetch", "prefetch_report"}, false},
+		{"unknown target", []string{"foo"}, nil, nil, true},
+		{"unknown skip", nil, []string{"foo"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := &Workflow{Tasks: map[string]Task{}}
+			for name, task := range tasks {
+				workflow.Tasks[name] = task
+			}
+			workflow.SetupGraph()
+
+			err := workflow.Select(tt.targets, tt.skip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []string
+			for name := range workflow.Tasks {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() got = %v, want %v", got, tt.want)
+			}
+			if len(workflow.graph.Vertices()) != len(tt.want) {
+				t.Errorf("Select() graph has %d tasks, want %d", len(workflow.graph.Vertices()), len(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+// taskState is stored as workflow_task element for every task of a run.
+type taskState struct {
+	Type          string   `json:"type"`
+	Run           string   `json:"run"`
+	Workflow      string   `json:"workflow"`
+	Task          string   `json:"task"`
+	Parent        string   `json:"parent,omitempty"`
+	Command       string   `json:"command"`
+	Arguments     []string `json:"arguments"`
+	Status        Status   `json:"status"`
+	Error         string   `json:"error,omitempty"`
+	StartTime     string   `json:"start_time"`
+	EndTime       string   `json:"end_time"`
+	Duration      float64  `json:"duration"`
+	Attempts      int      `json:"attempts"`
+	ElementsAdded int64    `json:"elements_added"`
+}
+
+// runState is stored as workflow_run element for every run.
+type runState struct {
+	ID            string  `json:"id"`
+	Type          string  `json:"type"`
+	Workflow      string  `json:"workflow"`
+	Status        Status  `json:"status"`
+	Error         string  `json:"error,omitempty"`
+	StartTime     string  `json:"start_time"`
+	EndTime       string  `json:"end_time"`
+	Duration      float64 `json:"duration"`
+	ElementsAdded int64   `json:"elements_added"`
+}
+
+// Hash returns a sha256 hash of the task definitions of the workflow. Runs
+// of a workflow reduced by Select keep the hash of the full workflow.
+func (workflow *Workflow) Hash() string {
+	if workflow.hash != "" {
+		return workflow.hash
+	}
+	b, err := yaml.Marshal(workflow.Tasks)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// succeededBefore checks the forensicstore for a successful run of the task
+// with identical command and arguments.
+func (r *run) succeededBefore(name string, task Task) bool {
+	r.limits.store.RLock()
+	defer r.limits.store.RUnlock()
+
+	store, teardown, err := forensicstore.Open(r.storeDir)
+	if err != nil {
+		log.Printf("could not read task state: %s", err)
+		return false
+	}
+	defer teardown()
+
+	elements, err := store.Select(Filter{{"type": "workflow_task", "status": string(StatusSucceeded)}})
+	if err != nil {
+		log.Printf("could not read task state: %s", err)
+		return false
+	}
+
+	args := task.cmdline()
+	for _, element := range elements {
+		state := taskState{}
+		if err := json.Unmarshal(element, &state); err != nil {
+			continue
+		}
+		if state.Task == name && state.Command == task.Command && equalArguments(state.Arguments, args) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalArguments(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// recordTask completes the report of a task and adds the state of the task
+// to the forensicstore. Sub-tasks of foreach tasks reference their parent
+// task.
+func (r *run) recordTask(name, parent string, task Task, status Status, start time.Time, taskErr error) {
+	end := time.Now()
+
+	r.statusMux.Lock()
+	report := r.taskReport(name)
+	report.Command = task.Command
+	report.Arguments = task.cmdline()
+	report.Outputs = task.Outputs
+	report.Status = status
+	report.StartTime = start.UTC()
+	report.EndTime = end.UTC()
+	report.Duration = end.Sub(start).Seconds()
+	report.parent = parent
+	if taskErr != nil {
+		report.Error = taskErr.Error()
+	}
+	for _, sub := range r.reports {
+		if sub.parent == name {
+			report.ElementsAdded += sub.ElementsAdded
+		}
+	}
+	state := taskState{
+		Type:          "workflow_task",
+		Run:           r.id,
+		Workflow:      r.hash,
+		Task:          name,
+		Parent:        parent,
+		Command:       task.Command,
+		Arguments:     report.Arguments,
+		Status:        status,
+		Error:         report.Error,
+		StartTime:     start.UTC().Format(time.RFC3339Nano),
+		EndTime:       end.UTC().Format(time.RFC3339Nano),
+		Duration:      report.Duration,
+		Attempts:      report.Attempts,
+		ElementsAdded: report.ElementsAdded,
+	}
+	r.statusMux.Unlock()
+
+	r.emit(Event{
+		Type: TaskFinished, Task: name, Parent: parent, Attempt: state.Attempts, Status: status, Error: state.Error,
+	})
+	r.insert(state)
+}
+
+// recordRun adds the state of the run to the forensicstore and returns the
+// report of the run.
+func (r *run) recordRun(start time.Time, runErr error) *Report {
+	report := r.report(start, runErr)
+	state := runState{
+		ID:            r.id,
+		Type:          "workflow_run",
+		Workflow:      report.Workflow,
+		Status:        report.Status,
+		Error:         report.Error,
+		StartTime:     start.UTC().Format(time.RFC3339Nano),
+		EndTime:       report.EndTime.Format(time.RFC3339Nano),
+		Duration:      report.Duration,
+		ElementsAdded: report.ElementsAdded,
+	}
+	r.insert(state)
+	r.emit(Event{Type: WorkflowFinished, Status: report.Status, Error: report.Error})
+	return report
+}
+
+func (r *run) insert(state interface{}) {
+	r.limits.store.Lock()
+	defer r.limits.store.Unlock()
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("could not record state: %s", err)
+		return
+	}
+
+	store, teardown, err := forensicstore.Open(r.storeDir)
+	if err != nil {
+		log.Printf("could not record state: %s", err)
+		return
+	}
+	defer teardown()
+
+	if _, err := store.Insert(b); err != nil {
+		log.Printf("could not record state: %s", err)
+	}
+}
+
+// newID creates a forensicstore id with a random uuid.
+func newID(elementType string) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	return fmt.Sprintf("%s--%x-%x-%x-%x-%x", elementType, b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/forensicanalysis/forensicstore"
+)
+
+func TestWorkflow_RunResume(t *testing.T) {
+	storeDir, cleanupStore := newTestStore(t)
+	defer cleanupStore()
+
+	runs := map[string]int{}
+	fail := true
+	plugins := map[string]testCommand{
+		"example": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+			runs["example"]++
+			return nil
+		}},
+		"flaky": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+			runs["flaky"]++
+			if fail {
+				return exitError(1)
+			}
+			return nil
+		}},
+	}
+
+	workflow := &Workflow{Tasks: map[string]Task{
+		"first":  {Command: "example", Arguments: map[string]interface{}{"foo": "bar"}},
+		"second": {Command: "flaky", Requires: []string{"first"}},
+	}}
+	workflow.SetupGraph()
+	if err := workflow.Run(context.Background(), storeDir, executors(plugins)); err == nil {
+		t.Fatal("Run() expected error")
+	}
+
+	fail = false
+	workflow.Resume = true
+	if err := workflow.Run(context.Background(), storeDir, executors(plugins)); err != nil {
+		t.Fatal(err)
+	}
+	if runs["example"] != 1 || runs["flaky"] != 2 {
+		t.Errorf("Run() runs = %v, want example once and flaky twice", runs)
+	}
+
+	workflow.Tasks["first"] = Task{Command: "example", Arguments: map[string]interface{}{"foo": "baz"}}
+	if err := workflow.Run(context.Background(), storeDir, executors(plugins)); err != nil {
+		t.Fatal(err)
+	}
+	if runs["example"] != 2 {
+		t.Errorf("Run() runs = %v, want rerun of example with changed arguments", runs)
+	}
+
+	store, teardown, err := forensicstore.Open(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	runElements, err := store.Select(Filter{{"type": "workflow_run"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runElements) != 3 {
+		t.Errorf("got %d workflow_run elements, want 3", len(runElements))
+	}
+	taskElements, err := store.Select(Filter{{"type": "workflow_task"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taskElements) != 6 {
+		t.Errorf("got %d workflow_task elements, want 6", len(taskElements))
+	}
+}
+
+func Test_newID(t *testing.T) {
+	id := newID("workflow_run")
+	if !regexp.MustCompile(`^workflow_run--[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("newID() = %s, not a valid id", id)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ExpandStores resolves forensicstore arguments. Arguments can be
+// forensicstores, glob patterns or directories that contain forensicstores.
+func ExpandStores(args []string) ([]string, error) {
+	var stores []string
+	seen := map[string]bool{}
+	add := func(store string) {
+		if !seen[store] {
+			seen[store] = true
+			stores = append(stores, store)
+		}
+	}
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		switch {
+		case err == nil && info.IsDir() && filepath.Ext(arg) != ".forensicstore":
+			matches, err := filepath.Glob(filepath.Join(arg, "*.forensicstore"))
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: no forensicstores found", arg)
+			}
+			for _, match := range matches {
+				add(match)
+			}
+		case err == nil:
+			add(arg)
+		default:
+			matches, globErr := filepath.Glob(arg)
+			if globErr != nil {
+				return nil, globErr
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%s: %w", arg, os.ErrNotExist)
+			}
+			for _, match := range matches {
+				add(match)
+			}
+		}
+	}
+	return stores, nil
+}
+
+// A StoreResult is the result of a workflow run on a single forensicstore.
+type StoreResult struct {
+	Store    string
+	Duration time.Duration
+	// Report is nil if the run did not start, e.g. because it was cancelled.
+	Report *Report
+	Err    error
+}
+
+// StoresError is returned by RunStores if the workflow failed on
+// forensicstores.
+type StoresError struct {
+	// Failed is the number of forensicstores with failed runs.
+	Failed int
+	// Optional is the number of forensicstores where only tasks failed that
+	// allow failures.
+	Optional int
+	// Stores is the number of all forensicstores.
+	Stores int
+}
+
+func (e *StoresError) Error() string {
+	if e.Failed > 0 {
+		return fmt.Sprintf("workflow failed for %d of %d forensicstores", e.Failed, e.Stores)
+	}
+	return fmt.Sprintf("optional tasks failed for %d of %d forensicstores", e.Optional, e.Stores)
+}
+
+// ExitCode returns ExitFailed if the workflow failed on a forensicstore and
+// ExitOptionalFailures if only tasks that allow failures failed.
+func (e *StoresError) ExitCode() int {
+	if e.Failed > 0 {
+		return ExitFailed
+	}
+	return ExitOptionalFailures
+}
+
+// RunStores runs the workflow on every forensicstore, at most inFlight
+// forensicstores at the same time. done is called after every run that
+// started, e.g. to record the run in the forensicstore. The results are in
+// the order of the forensicstores.
+func (workflow *Workflow) RunStores(ctx context.Context, stores []string, executors map[string]Executor, inFlight int,
+	done func(store string, report *Report, err error)) ([]StoreResult, error) {
+	if inFlight < 1 {
+		inFlight = 1
+	}
+	results := make([]StoreResult, len(stores))
+	slots := make(chan struct{}, inFlight)
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			start := time.Now()
+			var report *Report
+			err := ctx.Err()
+			if err == nil {
+				log.Printf("run workflow on %s", store)
+				report, err = workflow.RunWithReport(ctx, store, executors)
+				if done != nil {
+					done(store, report, err)
+				}
+			}
+			if err != nil {
+				log.Printf("workflow failed on %s: %s", store, err)
+			}
+			results[i] = StoreResult{Store: store, Duration: time.Since(start), Report: report, Err: err}
+		}(i, store)
+	}
+	wg.Wait()
+	return results, storesError(results)
+}
+
+// storesError summarizes the errors of the runs, nil if all runs succeeded.
+func storesError(results []StoreResult) error {
+	storesErr := &StoresError{Stores: len(results)}
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		var runErr *RunError
+		if errors.As(result.Err, &runErr) && runErr.ExitCode() == ExitOptionalFailures {
+			storesErr.Optional++
+		} else {
+			storesErr.Failed++
+		}
+	}
+	if storesErr.Failed == 0 && storesErr.Optional == 0 {
+		return nil
+	}
+	return storesErr
+}
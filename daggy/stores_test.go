@@ -0,0 +1,211 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestStores creates empty forensicstores in dir.
+func newTestStores(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		storeDir, cleanupStore := newTestStore(t)
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(storeDir, path); err != nil {
+			t.Fatal(err)
+		}
+		cleanupStore()
+	}
+}
+
+func Test_ExpandStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "expandstores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	newTestStores(t, dir, "a.forensicstore", "b.forensicstore", "cases/c.forensicstore", "cases/d.forensicstore")
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{"stores", []string{"b.forensicstore", "a.forensicstore"}, []string{"b.forensicstore", "a.forensicstore"}, false},
+		{"directory", []string{"cases"}, []string{"cases/c.forensicstore", "cases/d.forensicstore"}, false},
+		{"glob", []string{"*.forensicstore"}, []string{"a.forensicstore", "b.forensicstore"}, false},
+		{"duplicates", []string{"cases/d.forensicstore", "cases", "cases/*"}, []string{"cases/d.forensicstore", "cases/c.forensicstore"}, false},
+		{"empty directory", []string{"empty"}, nil, true},
+		{"missing store", []string{"x.forensicstore"}, nil, true},
+		{"glob without match", []string{"cases/*.zip"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []string
+			for _, arg := range tt.args {
+				args = append(args, filepath.Join(dir, arg))
+			}
+			got, err := ExpandStores(args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandStores() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, store := range got {
+				name, _ := filepath.Rel(dir, store)
+				names = append(names, filepath.ToSlash(name))
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("ExpandStores() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func Test_storesError(t *testing.T) {
+	failed := &RunError{Failed: map[string]string{"a": "broken"}, Allowed: map[string]string{}}
+	optional := &RunError{Failed: map[string]string{}, Allowed: map[string]string{"a": "broken"}}
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantExitCode int
+	}{
+		{"no stores", nil, ExitSucceeded},
+		{"succeeded", []error{nil, nil}, ExitSucceeded},
+		{"optional failures", []error{nil, optional}, ExitOptionalFailures},
+		{"failed", []error{optional, failed, nil}, ExitFailed},
+		{"cancelled", []error{context.Canceled}, ExitFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []StoreResult
+			for _, err := range tt.errs {
+				results = append(results, StoreResult{Store: "test.forensicstore", Err: err})
+			}
+			err := storesError(results)
+			exitCode := ExitSucceeded
+			if err != nil {
+				var exitErr *StoresError
+				if !errors.As(err, &exitErr) {
+					t.Fatalf("storesError() = %v, want StoresError", err)
+				}
+				exitCode = exitErr.ExitCode()
+			}
+			if exitCode != tt.wantExitCode {
+				t.Errorf("storesError() exit code = %d, want %d (%v)", exitCode, tt.wantExitCode, err)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runstores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	newTestStores(t, dir, "good.forensicstore", "bad.forensicstore", "other.forensicstore")
+	stores := []string{
+		filepath.Join(dir, "good.forensicstore"),
+		filepath.Join(dir, "bad.forensicstore"),
+		filepath.Join(dir, "other.forensicstore"),
+	}
+
+	// the task fails on forensicstores named bad
+	executors := map[string]Executor{"check": ExecutorFunc(func(ctx context.Context, store string, spec TaskSpec) (Result, error) {
+		if strings.HasPrefix(filepath.Base(store), "bad") {
+			return Result{ExitCode: 1}, errors.New("broken")
+		}
+		return Result{}, nil
+	})}
+	workflow := &Workflow{Tasks: map[string]Task{"check": {Command: "check"}}, NoCache: true}
+	workflow.SetupGraph()
+
+	tests := []struct {
+		name      string
+		cancelled bool
+		inFlight  int
+		wantErrs  []bool
+	}{
+		{"sequential", false, 1, []bool{false, true, false}},
+		{"parallel", false, 2, []bool{false, true, false}},
+		{"no limit", false, 0, []bool{false, true, false}},
+		{"cancelled", true, 2, []bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			var mux sync.Mutex
+			var recorded []string
+			done := func(store string, report *Report, err error) {
+				mux.Lock()
+				defer mux.Unlock()
+				recorded = append(recorded, store)
+			}
+
+			results, err := workflow.RunStores(ctx, stores, executors, tt.inFlight, done)
+			if err == nil {
+				t.Error("RunStores() expected error")
+			}
+			if len(results) != len(stores) {
+				t.Fatalf("RunStores() got %d results, want %d", len(results), len(stores))
+			}
+			for i, result := range results {
+				if result.Store != stores[i] {
+					t.Errorf("RunStores() result %d is for %s, want %s", i, result.Store, stores[i])
+				}
+				if (result.Err != nil) != tt.wantErrs[i] {
+					t.Errorf("RunStores() %s error = %v, want error %v", result.Store, result.Err, tt.wantErrs[i])
+				}
+				if (result.Report != nil) == tt.cancelled {
+					t.Errorf("RunStores() %s report = %v", result.Store, result.Report)
+				}
+			}
+			wantRecorded := len(stores)
+			if tt.cancelled {
+				wantRecorded = 0
+			}
+			if len(recorded) != wantRecorded {
+				t.Errorf("RunStores() recorded %v, want %d runs", recorded, wantRecorded)
+			}
+		})
+	}
+}
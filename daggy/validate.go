@@ -0,0 +1,332 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// A Position is a location in a workflow file.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// A Problem is an error in a workflow definition found by Validate.
+type Problem struct {
+	Position Position
+	Task     string
+	Message  string
+}
+
+func (p Problem) String() string {
+	message := p.Message
+	if p.Task != "" {
+		message = fmt.Sprintf("task %s: %s", p.Task, message)
+	}
+	if p.Position.File == "" {
+		return message
+	}
+	return fmt.Sprintf("%s: %s", p.Position, message)
+}
+
+// Validate parses a workflow file and checks it without running it: every
+// command must exist, arguments must be flags of the command with values of
+// the matching type, required tasks and hooks must exist and the tasks must
+// not form a cycle.
+func Validate(workflowFile string, builtins map[string][]byte, executors map[string]Executor) ([]Problem, error) {
+	p := &parser{builtins: builtins}
+	workflow, positions, err := p.parseFile(workflowFile, nil)
+	if err != nil {
+		return nil, err
+	}
+	return workflow.validate(positions, executors), nil
+}
+
+// reportFunc adds a problem of a task at the first known position of the
+// paths.
+type reportFunc func(name string, paths []string, format string, a ...interface{})
+
+func problemReporter(positions map[string]Position, problems *[]Problem) reportFunc {
+	return func(name string, paths []string, format string, a ...interface{}) {
+		problem := Problem{Task: name, Message: fmt.Sprintf(format, a...)}
+		for _, path := range paths {
+			if position, ok := positions[path]; ok {
+				problem.Position = position
+				break
+			}
+		}
+		*problems = append(*problems, problem)
+	}
+}
+
+// Validate checks the tasks and the graph of the workflow: retries must not
+// be negative, required tasks and hooks must exist and the tasks must not form
+// a cycle.
+func (workflow *Workflow) Validate() error {
+	if workflow.graph == nil {
+		workflow.SetupGraph()
+	}
+	var problems []Problem
+	report := problemReporter(nil, &problems)
+	workflow.checkRetries(report)
+	workflow.checkGraph(report)
+	if len(problems) == 0 {
+		return nil
+	}
+	var messages []string
+	for _, problem := range problems {
+		messages = append(messages, problem.String())
+	}
+	return fmt.Errorf("invalid workflow: %s", strings.Join(messages, "; "))
+}
+
+func (workflow *Workflow) validate(positions map[string]Position, executors map[string]Executor) []Problem {
+	var problems []Problem
+	report := problemReporter(positions, &problems)
+
+	checkCommand := func(name, taskPath string, task Task) {
+		if task.Command == "" {
+			report(name, []string{taskPath}, "missing command")
+			return
+		}
+		executor, ok := executors[task.Command]
+		if !ok {
+			report(name, []string{taskPath + ".command", taskPath}, "unknown command %s", task.Command)
+			return
+		}
+		flags := describe(executor).Flags
+		if flags == nil {
+			return
+		}
+
+		var keys []string
+		for key := range task.Arguments {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			path := []string{taskPath + ".arguments." + key, taskPath + ".arguments", taskPath}
+			flag := flags.Lookup(key)
+			if flag == nil {
+				report(name, path, "unknown argument %s for command %s", key, task.Command)
+				continue
+			}
+			if err := checkType(flag, task.Arguments[key]); err != nil {
+				report(name, path, "argument %s: %s", key, err)
+			}
+		}
+	}
+
+	switch workflow.OnFailure.Policy {
+	case "", OnFailureContinue, OnFailureStop:
+	default:
+		report("", []string{"on_failure.policy", "on_failure"}, "unknown failure policy %s, use %s or %s",
+			workflow.OnFailure.Policy, OnFailureContinue, OnFailureStop)
+	}
+
+	var names []string
+	for name := range workflow.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checkCommand(name, "tasks."+name, workflow.Tasks[name])
+	}
+	workflow.checkRetries(report)
+
+	workflow.SetupGraph()
+	workflow.checkGraph(report)
+	return problems
+}
+
+// checkRetries reports tasks with negative retries.
+func (workflow *Workflow) checkRetries(report reportFunc) {
+	var names []string
+	for name := range workflow.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if retries := workflow.Tasks[name].Retries; retries < 0 {
+			report(name, []string{"tasks." + name + ".retries", "tasks." + name}, "negative retries %d", retries)
+		}
+	}
+}
+
+// checkGraph reports unknown required tasks, cycles and invalid hooks.
+func (workflow *Workflow) checkGraph(report reportFunc) {
+	var names []string
+	for name := range workflow.Tasks {
+		if !workflow.isHook(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		taskPath := "tasks." + name
+		for _, requirement := range workflow.Tasks[name].Requires {
+			paths := []string{taskPath + ".requires[" + requirement + "]", taskPath + ".requires", taskPath}
+			if _, ok := workflow.Tasks[requirement]; requirement == name {
+				report(name, paths, "task requires itself")
+			} else if !ok {
+				report(name, paths, "unknown required task %s", requirement)
+			} else if workflow.isHook(requirement) {
+				report(name, paths, "task requires hook %s", requirement)
+			}
+		}
+	}
+
+	hooks := workflow.hooks()
+	checked := map[string]bool{}
+	for _, point := range hookPoints {
+		for _, name := range hooks[point] {
+			task, ok := workflow.Tasks[name]
+			switch {
+			case !ok:
+				paths := []string{point + "[" + name + "]", point + ".hooks[" + name + "]", point}
+				report("", paths, "unknown %s hook %s", point, name)
+			case checked[name]:
+			case len(task.Requires) > 0 || task.When != nil || task.Foreach != nil || len(task.Outputs) > 0:
+				report(name, []string{"tasks." + name}, "hooks do not support requires, when, foreach and outputs")
+			}
+			checked[name] = true
+		}
+	}
+
+	for _, cycle := range workflow.graph.Cycles() {
+		var tasks []string
+		for _, vertex := range cycle {
+			tasks = append(tasks, fmt.Sprint(vertex))
+		}
+		sort.Strings(tasks)
+		report("", []string{"tasks." + tasks[0], "tasks"}, "cycle between tasks %s", strings.Join(tasks, ", "))
+	}
+}
+
+// checkType checks if an argument value can be passed to a flag.
+func checkType(flag *pflag.Flag, value interface{}) error {
+	if s, ok := value.(string); ok && variablePattern.MatchString(s) {
+		// resolved at run time
+		return nil
+	}
+
+	flagType := flag.Value.Type()
+	switch flagType {
+	case "stringArray", "stringSlice":
+		return nil
+	case "string":
+		switch value.(type) {
+		case []interface{}, map[interface{}]interface{}, map[string]interface{}:
+			return fmt.Errorf("expected a single value, got %T", value)
+		}
+		return nil
+	case "object":
+		// objects are passed as JSON
+		switch v := value.(type) {
+		case map[interface{}]interface{}, map[string]interface{}:
+			return nil
+		case string:
+			var object map[string]interface{}
+			if json.Unmarshal([]byte(v), &object) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected an object, got %T", value)
+	}
+
+	s := fmt.Sprint(value)
+	var err error
+	switch flagType {
+	case "bool":
+		_, err = strconv.ParseBool(s)
+	case "int", "int8", "int16", "int32", "int64":
+		_, err = strconv.ParseInt(s, 0, 64)
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		_, err = strconv.ParseUint(s, 0, 64)
+	case "float32", "float64":
+		_, err = strconv.ParseFloat(s, 64)
+	case "duration":
+		_, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return fmt.Errorf("expected %s, got %q", flagType, s)
+	}
+	return nil
+}
+
+// yamlPositions returns the positions of the keys and list items in a yaml
+// file. Keys are joined with dots, list items are appended in brackets with
+// their value, or their index if they are not a scalar, e.g.
+// "tasks.report.requires[prefetch]". Files that cannot be parsed have no
+// positions.
+func yamlPositions(data []byte, file string) map[string]Position {
+	positions := map[string]Position{}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return positions
+	}
+	for _, node := range document.Content {
+		addPositions(positions, node, "", file)
+	}
+	return positions
+}
+
+func addPositions(positions map[string]Position, node *yaml.Node, path, file string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			positions[key] = Position{File: file, Line: node.Content[i].Line, Column: node.Content[i].Column}
+			addPositions(positions, node.Content[i+1], key, file)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if item.Kind == yaml.ScalarNode {
+				itemPath = path + "[" + item.Value + "]"
+			}
+			positions[itemPath] = Position{File: file, Line: item.Line, Column: item.Column}
+			addPositions(positions, item, itemPath, file)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// objectFlag is a flag for object properties of script and docker plugins.
+type objectFlag string
+
+func (f *objectFlag) String() string     { return string(*f) }
+func (f *objectFlag) Set(s string) error { *f = objectFlag(s); return nil }
+func (f *objectFlag) Type() string       { return "object" }
+
+func TestValidate(t *testing.T) {
+	flags := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	flags.Bool("add-to-store", false, "")
+	flags.Int("limit", 0, "")
+	flags.String("output", "", "")
+	flags.StringArray("filter", nil, "")
+	flags.Var(new(objectFlag), "options", "")
+	plugins := map[string]testCommand{"example": {info: CommandInfo{Flags: flags}}}
+
+	tests := []struct {
+		name     string
+		workflow string
+		want     []string
+		wantErr  bool
+	}{
+		{"valid", `
+tasks:
+  a:
+    command: example
+    arguments:
+      add-to-store: true
+      limit: 3
+      output: ${dir}/out.csv
+      filter:
+        - type: file
+  b:
+    command: example
+    requires: [a]
+`, nil, false},
+		{"unknown command", `
+tasks:
+  a:
+    command: exmaple
+`, []string{"test.yml:4:5: task a: unknown command exmaple"}, false},
+		{"unknown argument", `
+tasks:
+  a:
+    command: example
+    arguments:
+      ouput: x.csv
+`, []string{"test.yml:6:7: task a: unknown argument ouput for command example"}, false},
+		{"wrong types", `
+tasks:
+  a:
+    command: example
+    arguments:
+      add-to-store: yes please
+      limit: "3.5"
+      output: [a, b]
+`, []string{
+			`test.yml:6:7: task a: argument add-to-store: expected bool, got "yes please"`,
+			`test.yml:7:7: task a: argument limit: expected int, got "3.5"`,
+			`test.yml:8:7: task a: argument output: expected a single value, got []interface {}`,
+		}, false},
+		{"unknown requirement", `
+tasks:
+  a:
+    command: example
+    requires:
+      - b
+      - c
+  b:
+    command: example
+`, []string{"test.yml:7:9: task a: unknown required task c"}, false},
+		{"cycle", `
+tasks:
+  a:
+    command: example
+    requires: [b]
+  b:
+    command: example
+    requires: [a]
+`, []string{"test.yml:3:3: cycle between tasks a, b"}, false},
+		{"hooks", `
+tasks:
+  a:
+    command: example
+  export:
+    command: example
+    arguments:
+      output: ${report}
+  marker:
+    command: exmaple
+    requires: [a]
+on_success: [export]
+on_failure:
+  policy: stop
+  hooks: [missing]
+finally: [marker]
+`, []string{
+			"test.yml:10:5: task marker: unknown command exmaple",
+			"test.yml:15:11: unknown on_failure hook missing",
+			"test.yml:9:3: task marker: hooks do not support requires, when, foreach and outputs",
+		}, false},
+		{"object argument", `
+tasks:
+  a:
+    command: example
+    arguments:
+      options:
+        depth: 2
+  b:
+    command: example
+    arguments:
+      options: [depth]
+`, []string{"test.yml:11:7: task b: argument options: expected an object, got []interface {}"}, false},
+		{"negative retries", `
+tasks:
+  a:
+    command: example
+    retries: -1
+`, []string{"test.yml:5:5: task a: negative retries -1"}, false},
+		{"invalid yaml", "tasks: [", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "validatetest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			workflowFile := filepath.Join(dir, "test.yml")
+			if err := ioutil.WriteFile(workflowFile, []byte(tt.workflow), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			problems, err := Validate(workflowFile, nil, executors(plugins))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, problem := range problems {
+				problem.Position.File = filepath.Base(problem.Position.File)
+				got = append(got, problem.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_yamlPositions(t *testing.T) {
+	data := []byte(`# comment
+tasks:
+  report:
+    command: report # trailing
+    requires: [prefetch, "hash"]
+    arguments:
+      "filter":
+        - type: file
+      query: |
+        - not: a list item
+        key: not a key
+  'plugins': {command: plugins}
+`)
+	want := map[string]Position{
+		"tasks":                                 {"f", 2, 1},
+		"tasks.report":                          {"f", 3, 3},
+		"tasks.report.command":                  {"f", 4, 5},
+		"tasks.report.requires":                 {"f", 5, 5},
+		"tasks.report.requires[prefetch]":       {"f", 5, 16},
+		"tasks.report.requires[hash]":           {"f", 5, 26},
+		"tasks.report.arguments":                {"f", 6, 5},
+		"tasks.report.arguments.filter":         {"f", 7, 7},
+		"tasks.report.arguments.filter[0]":      {"f", 8, 11},
+		"tasks.report.arguments.filter[0].type": {"f", 8, 11},
+		"tasks.report.arguments.query":          {"f", 9, 7},
+		"tasks.plugins":                         {"f", 12, 3},
+		"tasks.plugins.command":                 {"f", 12, 15},
+	}
+	if got := yamlPositions(data, "f"); !reflect.DeepEqual(got, want) {
+		t.Errorf("yamlPositions() = %v, want %v", got, want)
+	}
+	if got := yamlPositions([]byte("tasks: ["), "f"); len(got) != 0 {
+		t.Errorf("yamlPositions() = %v, want no positions", got)
+	}
+}
+
+func TestWorkflow_RunInvalidGraph(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks map[string]Task
+	}{
+		{"cycle", map[string]Task{
+			"a": {Command: "ok", Requires: []string{"b"}},
+			"b": {Command: "ok", Requires: []string{"a"}},
+		}},
+		{"unknown requirement", map[string]Task{"a": {Command: "ok", Requires: []string{"missing"}}}},
+		{"self requirement", map[string]Task{"a": {Command: "ok", Requires: []string{"a"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := false
+			executor := ExecutorFunc(func(ctx context.Context, store string, spec TaskSpec) (Result, error) {
+				ran = true
+				return Result{}, nil
+			})
+			workflow := Workflow{Tasks: tt.tasks, NoCache: true}
+			workflow.SetupGraph()
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := workflow.RunWithReport(context.Background(), "test.forensicstore", map[string]Executor{"ok": executor})
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if err == nil || ran {
+					t.Errorf("RunWithReport() error = %v, ran %v, want error without running", err, ran)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("RunWithReport() did not return")
+			}
+			if _, err := workflow.Plan(); err == nil {
+				t.Error("Plan() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// variablePattern matches ${name} references, $${name} escapes a reference.
+var variablePattern = regexp.MustCompile(`\$?\$\{([^}]*)\}`) // nolint: gochecknoglobals
+
+// lookupFunc resolves the name of a variable reference.
+type lookupFunc func(name string) (string, error)
+
+// lookup resolves ${name} from the workflow variables and ${env.NAME} from
+// the environment.
+func (workflow *Workflow) lookup(name string) (string, error) {
+	if strings.HasPrefix(name, "env.") {
+		if value, ok := os.LookupEnv(strings.TrimPrefix(name, "env.")); ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("environment variable %s not set", strings.TrimPrefix(name, "env."))
+	}
+	if value, ok := workflow.Vars[name]; ok {
+		return interpolateString(value, workflow.lookupEnv)
+	}
+	return "", fmt.Errorf("unknown variable %s", name)
+}
+
+// lookupEnv resolves only environment variables, so variables cannot
+// reference each other.
+func (workflow *Workflow) lookupEnv(name string) (string, error) {
+	if !strings.HasPrefix(name, "env.") {
+		return "", fmt.Errorf("variables can only reference environment variables, not %s", name)
+	}
+	return workflow.lookup(name)
+}
+
+// lookupTask resolves the references in a task. Besides variables these
+// are the working directory of the run as ${workdir}, outputs of the task as
+// ${outputs.<key>}, outputs of required tasks as
+// ${tasks.<name>.outputs.<key>} and the current item of foreach tasks as
+// ${item}.
+func (workflow *Workflow) lookupTask(name, workdir, item string) lookupFunc {
+	return func(ref string) (string, error) {
+		switch {
+		case ref == "item" && workflow.Tasks[name].Foreach != nil:
+			return item, nil
+		case ref == "workdir":
+			return workdir, nil
+		case strings.HasPrefix(ref, "outputs."):
+			return workflow.outputPath(workdir, name, strings.TrimPrefix(ref, "outputs."), item)
+		case strings.HasPrefix(ref, "tasks."):
+			parts := strings.SplitN(strings.TrimPrefix(ref, "tasks."), ".outputs.", 2)
+			if len(parts) != 2 { //nolint: gomnd
+				return "", fmt.Errorf("invalid reference %s, use tasks.<name>.outputs.<key>", ref)
+			}
+			if !workflow.requires(name, parts[0]) {
+				return "", fmt.Errorf("outputs of task %s can only be used by tasks that require it", parts[0])
+			}
+			return workflow.outputPath(workdir, parts[0], parts[1], "")
+		}
+		return workflow.lookup(ref)
+	}
+}
+
+// lookupHook resolves the references in a hook. Besides variables these
+// are the working directory of the run as ${workdir} and the report of the
+// run as ${report}.
+func (workflow *Workflow) lookupHook(workdir, reportFile string) lookupFunc {
+	return func(ref string) (string, error) {
+		switch ref {
+		case "report":
+			return reportFile, nil
+		case "workdir":
+			return workdir, nil
+		}
+		return workflow.lookup(ref)
+	}
+}
+
+// resolveTasks returns the tasks with all references in arguments and
+// conditions replaced and the paths of their outputs in the working
+// directory. Hooks are resolved when they run.
+func (workflow *Workflow) resolveTasks(workdir string) (map[string]Task, error) {
+	tasks := map[string]Task{}
+	for name, task := range workflow.Tasks {
+		if workflow.isHook(name) {
+			continue
+		}
+		if task.Foreach != nil {
+			// arguments and outputs of foreach tasks are resolved per item by
+			// forItem, a placeholder item checks the references
+			resolved, err := resolveTask(task, workflow.lookupTask(name, workdir, "<item>"))
+			if err != nil {
+				return nil, fmt.Errorf("task %s: %w", name, err)
+			}
+			resolved.Arguments = task.Arguments
+			resolved.Outputs = nil
+			tasks[name] = resolved
+			continue
+		}
+
+		resolved, err := resolveTask(task, workflow.lookupTask(name, workdir, ""))
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w", name, err)
+		}
+		if task.Outputs != nil {
+			resolved.Outputs = map[string]string{}
+			for key := range task.Outputs {
+				if resolved.Outputs[key], err = workflow.outputPath(workdir, name, key, ""); err != nil {
+					return nil, fmt.Errorf("task %s: %w", name, err)
+				}
+			}
+		}
+		tasks[name] = resolved
+	}
+	return tasks, nil
+}
+
+func resolveTask(task Task, lookup lookupFunc) (Task, error) {
+	arguments, err := interpolate(task.Arguments, lookup)
+	if err != nil {
+		return task, err
+	}
+	task.Arguments, _ = arguments.(map[string]interface{})
+
+	if task.When != nil {
+		when := *task.When
+		when.Filter, err = interpolateFilter(when.Filter, lookup)
+		if err != nil {
+			return task, err
+		}
+		task.When = &when
+	}
+	if task.Foreach != nil {
+		foreach := *task.Foreach
+		foreach.Filter, err = interpolateFilter(foreach.Filter, lookup)
+		if err != nil {
+			return task, err
+		}
+		foreach.Items = make([]string, len(task.Foreach.Items))
+		for i, item := range task.Foreach.Items {
+			if foreach.Items[i], err = interpolateString(item, lookup); err != nil {
+				return task, err
+			}
+		}
+		task.Foreach = &foreach
+	}
+	return task, nil
+}
+
+func interpolateFilter(filter Filter, lookup lookupFunc) (Filter, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	resolved := Filter{}
+	for _, condition := range filter {
+		resolvedCondition := map[string]string{}
+		for attribute, value := range condition {
+			s, err := interpolateString(value, lookup)
+			if err != nil {
+				return nil, err
+			}
+			resolvedCondition[attribute] = s
+		}
+		resolved = append(resolved, resolvedCondition)
+	}
+	return resolved, nil
+}
+
+// interpolate replaces variable references in all strings of a value
+// parsed from yaml.
+func interpolate(value interface{}, lookup lookupFunc) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return interpolateString(v, lookup)
+	case []interface{}:
+		if v == nil {
+			return v, nil
+		}
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if resolved[i], err = interpolate(item, lookup); err != nil {
+				return nil, err
+			}
+		}
+		return resolved, nil
+	case map[string]interface{}:
+		if v == nil {
+			return v, nil
+		}
+		resolved := map[string]interface{}{}
+		for key, item := range v {
+			var err error
+			if resolved[key], err = interpolate(item, lookup); err != nil {
+				return nil, err
+			}
+		}
+		return resolved, nil
+	case map[interface{}]interface{}:
+		resolved := map[interface{}]interface{}{}
+		for key, item := range v {
+			var err error
+			if resolved[key], err = interpolate(item, lookup); err != nil {
+				return nil, err
+			}
+		}
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
+
+func interpolateString(s string, lookup lookupFunc) (string, error) {
+	var err error
+	resolved := variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		value, lookupErr := lookup(strings.TrimSpace(match[2 : len(match)-1]))
+		if lookupErr != nil && err == nil {
+			err = lookupErr
+		}
+		return value
+	})
+	return resolved, err
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Siemens AG
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+//
+// Author(s): Jonas Plum
+
+package daggy
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWorkflow_resolveTasks(t *testing.T) {
+	os.Setenv("DAGGY_TEST_CASE", "case-42")
+	defer os.Unsetenv("DAGGY_TEST_CASE")
+
+	vars := map[string]string{"output": "/cases", "case": "${env.DAGGY_TEST_CASE}", "loop": "${output}"}
+
+	tests := []struct {
+		name    string
+		task    Task
+		want    Task
+		wantErr bool
+	}{
+		{"no arguments", Task{Command: "example"}, Task{Command: "example"}, false},
+		{
+			"variable",
+			Task{Arguments: map[string]interface{}{"output": "${output}/hotfixes.txt"}},
+			Task{Arguments: map[string]interface{}{"output": "/cases/hotfixes.txt"}},
+			false,
+		},
+		{
+			"environment",
+			Task{Arguments: map[string]interface{}{"output": "${ env.DAGGY_TEST_CASE }.csv", "case": "${case}"}},
+			Task{Arguments: map[string]interface{}{"output": "case-42.csv", "case": "case-42"}},
+			false,
+		},
+		{
+			"nested",
+			Task{Arguments: map[string]interface{}{"filter": []interface{}{
+				map[interface{}]interface{}{"type": "file", "path": "${output}%"},
+			}}},
+			Task{Arguments: map[string]interface{}{"filter": []interface{}{
+				map[interface{}]interface{}{"type": "file", "path": "/cases%"},
+			}}},
+			false,
+		},
+		{
+			"condition",
+			Task{When: &Condition{Filter: Filter{{"path": "${output}%"}}}},
+			Task{When: &Condition{Filter: Filter{{"path": "/cases%"}}}},
+			false,
+		},
+		{
+			"escaped",
+			Task{Arguments: map[string]interface{}{"query": "$${output}", "count": 3}},
+			Task{Arguments: map[string]interface{}{"query": "${output}", "count": 3}},
+			false,
+		},
+		{"unknown variable", Task{Arguments: map[string]interface{}{"output": "${foo}"}}, Task{}, true},
+		{"unknown environment variable", Task{Arguments: map[string]interface{}{"output": "${env.DAGGY_NOT_SET}"}}, Task{}, true},
+		{"variable references variable", Task{Arguments: map[string]interface{}{"output": "${loop}"}}, Task{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := &Workflow{Tasks: map[string]Task{"test": tt.task}, Vars: vars}
+			got, err := workflow.resolveTasks("")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveTasks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got["test"], tt.want) {
+				t.Errorf("resolveTasks() got = %#v, want %#v", got["test"], tt.want)
+			}
+		})
+	}
+}
@@ -22,15 +22,20 @@
 package daggy
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/hashicorp/terraform/dag"
 	"github.com/hashicorp/terraform/tfdiags"
-	"github.com/spf13/cobra"
 )
 
 // A Task is a single element in a workflow yml file.
@@ -38,13 +43,83 @@ type Task struct {
 	Command   string                 `yaml:"command"`
 	Arguments map[string]interface{} `yaml:"arguments"`
 	Requires  []string               `yaml:"requires"`
+	Class     string                 `yaml:"class"`
+	Timeout   Duration               `yaml:"timeout"`
+
+	// Retries is the number of additional attempts for a failed task.
+	Retries    int      `yaml:"retries"`
+	RetryDelay Duration `yaml:"retry_delay"`
+	// RetryOn restricts retries to the given exit codes.
+	RetryOn []int `yaml:"retry_on"`
+
+	// When skips the task if the condition is not met by the forensicstore.
+	// Tasks that require a skipped task are skipped as well.
+	When *Condition `yaml:"when"`
+
+	// Foreach runs the task once per item, the sub-tasks are named
+	// task[item].
+	Foreach *Foreach `yaml:"foreach"`
+
+	// Outputs declares files the task writes by key and file name. The
+	// files are placed in the working directory of the run and can be
+	// referenced as ${outputs.<key>} by the task and as
+	// ${tasks.<name>.outputs.<key>} by tasks that require it. Foreach tasks
+	// write their outputs per item, the file name can contain ${item}, and
+	// only the task itself can reference them.
+	Outputs map[string]string `yaml:"outputs"`
+
+	// AllowFailure marks the task as optional, its failure does not fail
+	// the workflow. Tasks that require it are skipped nonetheless.
+	AllowFailure bool `yaml:"allow_failure"`
 }
 
 // Workflow can be used to parse workflow yml files.
 type Workflow struct {
 	Tasks map[string]Task `yaml:"tasks"`
+	// Include adds the tasks of other workflows, given by path or name.
+	Include map[string]string `yaml:"include"`
+	// Vars can be referenced as ${name} in task arguments, environment
+	// variables as ${env.NAME}.
+	Vars map[string]string `yaml:"vars"`
+
+	// Parallelism is the maximum number of tasks that run at the same time,
+	// defaults to the number of CPUs.
+	Parallelism int `yaml:"parallelism"`
+	// Classes limits the number of parallel tasks per concurrency class,
+	// classes without limit run one task at a time.
+	Classes map[string]int `yaml:"classes"`
+	// OnStart names the hooks that run before the first task, see HookOnStart.
+	OnStart []string `yaml:"on_start"`
+	// OnSuccess names the hooks that run after all tasks if no required task
+	// failed.
+	OnSuccess []string `yaml:"on_success"`
+	// OnFailure is the failure policy, "continue" (default) to run all tasks
+	// that do not depend on a failed task or "stop" to cancel running tasks
+	// and start no new tasks after a required task failed, and the hooks that
+	// run if a required task failed.
+	OnFailure Failure `yaml:"on_failure"`
+	// Finally names the hooks that run after the other hooks, regardless of
+	// the result.
+	Finally []string `yaml:"finally"`
+
+	// Resume skips tasks that already succeeded in a previous run with the
+	// same command and arguments.
+	Resume bool `yaml:"-"`
+	// NoCache runs tasks even if their inputs did not change since the last
+	// successful run.
+	NoCache bool `yaml:"-"`
+	// WorkDir is the directory in which the working directory of each run
+	// is created, defaults to the temporary directory.
+	WorkDir string `yaml:"-"`
+	// KeepWorkDir keeps the working directory of the run with all task
+	// outputs, instead of removing it after the run.
+	KeepWorkDir bool `yaml:"-"`
+	// Observer receives the events of all runs of the workflow.
+	Observer Observer `yaml:"-"`
+
 	graph *dag.AcyclicGraph
-	mux   sync.Mutex
+	// hash of the tasks before Select reduced them
+	hash string
 }
 
 // SetupGraph creates a direct acyclic graph of tasks.
@@ -54,12 +129,16 @@ func (workflow *Workflow) SetupGraph() {
 	graph := dag.AcyclicGraph{}
 	tasks := map[string]Task{}
 	for name, task := range workflow.Tasks {
+		// hooks run outside of the graph
+		if workflow.isHook(name) {
+			continue
+		}
 		graph.Add(name)
 		tasks[name] = task
 	}
 
 	// add edges / requirements
-	for name, task := range workflow.Tasks {
+	for name, task := range tasks {
 		for _, requirement := range task.Requires {
 			graph.Connect(dag.BasicEdge(requirement, name))
 		}
@@ -68,47 +147,157 @@ func (workflow *Workflow) SetupGraph() {
 	workflow.graph = &graph
 }
 
-// Run walks the direct acyclic graph to execute each task.
-func (workflow *Workflow) Run(storeDir string, plugins map[string]*cobra.Command) error {
-	w := &dag.Walker{Callback: func(v dag.Vertex) tfdiags.Diagnostics {
-		task := workflow.Tasks[v.(string)]
-
-		if plugin, ok := plugins[task.Command]; ok {
-			workflow.mux.Lock() // serialize tasks
-			err := workflow.runTask(plugin, task, storeDir)
-			workflow.mux.Unlock()
-			if err != nil {
-				return tfdiags.Diagnostics{tfdiags.Sourceless(tfdiags.Error, fmt.Sprint(v.(string)), err.Error())}
+// Run walks the direct acyclic graph to execute each task with the executor
+// of its command. Cancelling the context stops all running tasks and
+// prevents new tasks from being started.
+func (workflow *Workflow) Run(ctx context.Context, storeDir string, executors map[string]Executor) error {
+	_, err := workflow.RunWithReport(ctx, storeDir, executors)
+	return err
+}
+
+// RunWithReport runs the workflow like Run and returns a report of the run
+// and all tasks. The report is nil if the workflow could not be started.
+func (workflow *Workflow) RunWithReport(ctx context.Context, storeDir string,
+	executors map[string]Executor) (*Report, error) {
+	switch workflow.OnFailure.Policy {
+	case "", OnFailureContinue, OnFailureStop:
+	default:
+		return nil, fmt.Errorf("unknown failure policy %s, use %s or %s",
+			workflow.OnFailure.Policy, OnFailureContinue, OnFailureStop)
+	}
+	// a cycle would block the walker forever
+	if err := workflow.Validate(); err != nil {
+		return nil, err
+	}
+
+	workdir, err := ioutil.TempDir(workflow.WorkDir, "workflow")
+	if err != nil {
+		return nil, fmt.Errorf("could not create working directory: %w", err)
+	}
+	if workflow.KeepWorkDir {
+		log.Printf("keep working directory %s", workdir)
+	} else {
+		defer os.RemoveAll(workdir)
+	}
+
+	tasks, err := workflow.resolveTasks(workdir)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		for _, output := range task.Outputs {
+			if err := os.MkdirAll(filepath.Dir(output), 0700); err != nil {
+				return nil, fmt.Errorf("could not create working directory: %w", err)
 			}
-			return nil
 		}
-		return tfdiags.Diagnostics{tfdiags.Sourceless(tfdiags.Error, task.Command, "command not found")}
+	}
+
+	taskCtx, cancelTasks := context.WithCancel(ctx)
+	defer cancelTasks()
+	r := &run{
+		ctx:         ctx,
+		taskCtx:     taskCtx,
+		cancelTasks: cancelTasks,
+		id:          newID("workflow_run"),
+		workflow:    workflow,
+		hash:        workflow.Hash(),
+		tasks:       tasks,
+		storeDir:    storeDir,
+		workdir:     workdir,
+		executors:   executors,
+		limits:      newLimits(workflow),
+		statuses:    map[string]Status{},
+		reports:     map[string]*TaskReport{},
+	}
+	start := time.Now()
+	r.begin(start)
+
+	w := &dag.Walker{Callback: func(v dag.Vertex) tfdiags.Diagnostics {
+		return r.visit(v.(string))
 	}}
 	w.Update(workflow.graph)
-	return w.Wait().Err()
+	if err := w.Wait().Err(); err != nil {
+		return r.recordRun(start, err), err
+	}
+	err = r.runError()
+	r.finish(start, err)
+	return r.recordRun(start, err), err
 }
 
-func (workflow *Workflow) runTask(plugin *cobra.Command, task Task, storeDir string) error {
-	var args []string
-	for flag, value := range task.Arguments {
-		args = append(args, toCmdline(flag, value)...)
+func (workflow *Workflow) runTask(ctx context.Context, executor Executor, name string, task Task,
+	storeDir string) (Result, error) {
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(task.Timeout))
+		defer cancel()
 	}
-	args = append(args, storeDir)
 
-	err := plugin.ParseFlags(args)
-	if err != nil {
-		return err
+	spec := TaskSpec{Name: name, Command: task.Command, Arguments: task.Arguments}
+	result, err := executor.Execute(ctx, storeDir, spec)
+	if err != nil && ctx.Err() != nil {
+		return result, &InterruptedError{Cause: ctx.Err(), Timeout: task.Timeout, Err: err}
+	}
+	return result, err
+}
+
+// InterruptedError is returned for tasks that were cancelled or exceeded
+// their timeout. It matches its cause, context.Canceled or
+// context.DeadlineExceeded, with errors.Is.
+type InterruptedError struct {
+	Cause   error
+	Timeout Duration
+	// Err is the error of the task, if it was started.
+	Err error
+}
+
+func (e *InterruptedError) Error() string {
+	msg := "task cancelled"
+	if e.Cause == context.DeadlineExceeded {
+		msg = "task timed out"
+		if e.Timeout > 0 {
+			msg += fmt.Sprintf(" after %s", e.Timeout)
+		}
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is matches the cause of the interruption.
+func (e *InterruptedError) Is(target error) bool {
+	return target == e.Cause
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// cmdline converts the arguments of a task into command line flags.
+func (task Task) cmdline() []string {
+	var flags []string
+	for flag := range task.Arguments {
+		flags = append(flags, flag)
 	}
+	sort.Strings(flags)
 
-	// plugin.SetArgs(args)
-	if plugin.RunE == nil {
-		return fmt.Errorf("plugin %s cannot be run", plugin.Name())
+	var args []string
+	for _, flag := range flags {
+		args = append(args, toCmdline(flag, task.Arguments[flag])...)
 	}
-	return plugin.RunE(plugin, plugin.Flags().Args())
+	return args
 }
 
+// toCmdline converts an argument into command line flags. Lists are passed
+// as one flag per item and maps as JSON object.
 func toCmdline(name string, i interface{}) []string {
 	switch reflect.TypeOf(i).Kind() {
+	case reflect.Map:
+		b, err := json.Marshal(normalize(i))
+		if err != nil {
+			return []string{"--" + name, fmt.Sprint(i)}
+		}
+		return []string{"--" + name, string(b)}
 	case reflect.Slice:
 		var s []string
 		v := reflect.ValueOf(i)
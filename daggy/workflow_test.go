@@ -22,15 +22,19 @@
 package daggy
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/otiai10/copy"
-	"github.com/spf13/cobra"
 
 	"github.com/forensicanalysis/forensicstore"
 )
@@ -95,14 +99,9 @@ func Test_processJob(t *testing.T) {
 			workflow := Workflow{Tasks: map[string]Task{tt.args.taskName: tt.args.task}}
 			workflow.SetupGraph()
 
-			plugins := map[string]*cobra.Command{"example": &cobra.Command{
-				Use: "example",
-				RunE: func(cmd *cobra.Command, args []string) error {
-					return nil
-				},
-			}}
+			plugins := map[string]testCommand{"example": {}}
 
-			if err := workflow.Run(filepath.Join(storeDir, tt.storeName), plugins); (err != nil) != tt.wantErr {
+			if err := workflow.Run(context.Background(), filepath.Join(storeDir, tt.storeName), executors(plugins)); (err != nil) != tt.wantErr {
 				t.Errorf("runTask() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -128,6 +127,126 @@ func Test_processJob(t *testing.T) {
 	}
 }
 
+func TestWorkflow_RunParallel(t *testing.T) {
+	tests := []struct {
+		name        string
+		parallelism int
+		class       string
+		classes     map[string]int
+		wantMax     int32
+	}{
+		{"serial", 1, "", nil, 1},
+		{"parallel", 2, "", nil, 2},
+		{"class without limit", 4, "docker", nil, 1},
+		{"class with limit", 4, "docker", map[string]int{"docker": 3}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, maxRunning int32
+			plugins := map[string]testCommand{}
+			workflow := Workflow{Tasks: map[string]Task{}, Parallelism: tt.parallelism, Classes: tt.classes}
+			for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+				plugins[name] = testCommand{run: func(ctx context.Context, store string, spec TaskSpec) error {
+					current := atomic.AddInt32(&running, 1)
+					for {
+						max := atomic.LoadInt32(&maxRunning)
+						if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+							break
+						}
+					}
+					time.Sleep(50 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return nil
+				}}
+				workflow.Tasks[name] = Task{Command: name, Class: tt.class}
+			}
+			workflow.SetupGraph()
+
+			if err := workflow.Run(context.Background(), "test.forensicstore", executors(plugins)); err != nil {
+				t.Fatal(err)
+			}
+			if maxRunning != tt.wantMax {
+				t.Errorf("Run() max parallel tasks = %d, want %d", maxRunning, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestWorkflow_RunCancel(t *testing.T) {
+	plugins := map[string]testCommand{"wait": {run: func(ctx context.Context, store string, spec TaskSpec) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		task       Task
+		wantErr    string
+		wantCause  error
+		wantStatus Status
+	}{
+		{"timeout", context.Background(), Task{Command: "wait", Timeout: Duration(10 * time.Millisecond)}, "task timed out after 10ms", context.DeadlineExceeded, StatusTimedOut},
+		{"cancel", cancelled, Task{Command: "wait"}, "task cancelled", context.Canceled, StatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := Workflow{Tasks: map[string]Task{"test": tt.task}}
+			workflow.SetupGraph()
+
+			report, err := workflow.RunWithReport(tt.ctx, "test.forensicstore", executors(plugins))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("RunWithReport() error = %v, want %s", err, tt.wantErr)
+			}
+			if got := report.Tasks[0].Status; got != tt.wantStatus {
+				t.Errorf("RunWithReport() status = %s, want %s", got, tt.wantStatus)
+			}
+
+			_, err = workflow.runTask(tt.ctx, executors(plugins)["wait"], "test", tt.task, "test.forensicstore")
+			if !errors.Is(err, tt.wantCause) {
+				t.Errorf("runTask() error = %v, want %v", err, tt.wantCause)
+			}
+		})
+	}
+}
+
+// testCommand is a command for tests, it runs with the forensicstore and the
+// task and is described by its info.
+type testCommand struct {
+	run  func(ctx context.Context, store string, spec TaskSpec) error
+	info CommandInfo
+}
+
+func (c testCommand) Execute(ctx context.Context, store string, spec TaskSpec) (Result, error) {
+	if c.run == nil {
+		return Result{}, nil
+	}
+	return Result{}, c.run(ctx, store, spec)
+}
+
+func (c testCommand) Info() CommandInfo {
+	return c.info
+}
+
+// fails returns a command that fails with the message.
+func fails(message string) testCommand {
+	return testCommand{run: func(ctx context.Context, store string, spec TaskSpec) error {
+		return errors.New(message)
+	}}
+}
+
+// executors returns the commands as executors.
+func executors(commands map[string]testCommand) map[string]Executor {
+	executors := map[string]Executor{}
+	for name, command := range commands {
+		executors[name] = command
+	}
+	return executors
+}
+
 func Test_toCmdline(t *testing.T) {
 	var i interface{}
 	i = []map[string]string{
@@ -145,6 +264,8 @@ func Test_toCmdline(t *testing.T) {
 		want []string
 	}{
 		{"filter", args{"filter", i}, []string{"--filter", "bar=baz,foo=bar", "--filter", "a=b"}},
+		{"object", args{"options", map[interface{}]interface{}{"depth": 2, "names": []interface{}{"a", "b"}}},
+			[]string{"--options", `{"depth":2,"names":["a","b"]}`}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
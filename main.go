@@ -24,10 +24,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
@@ -35,7 +39,7 @@ import (
 )
 
 //go:generate go get github.com/cugu/go-resources/cmd/resources@v0.3.1
-//go:generate resources -package assets -output assets/config.generated.go -trim "config/" config/scripts/* config/req*
+//go:generate resources -package assets -output assets/config.generated.go -trim "config/" config/*/* config/req*
 //go:generate go mod tidy
 
 func main() {
@@ -56,11 +60,36 @@ func main() {
 		log.SetOutput(ioutil.Discard)
 	}
 
-	rootCmd.AddCommand(cmd.Run(), cmd.Install(), cmd.Workflow())
+	rootCmd.AddCommand(cmd.Run(), cmd.Install(), cmd.Workflow(), cmd.Watch(), cmd.Provenance())
 
-	err := rootCmd.Execute()
+	ctx, cancel := signalContext()
+	err := rootCmd.ExecuteContext(ctx)
+	cancel()
 	if err != nil {
 		fmt.Println(err)
+		// workflows exit with 2 if only tasks that allow failures failed
+		var coder interface{ ExitCode() int }
+		if errors.As(err, &coder) && coder.ExitCode() > 0 {
+			os.Exit(coder.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
+
+// signalContext returns a context that is cancelled on the first SIGINT or
+// SIGTERM. Any further signal terminates the program immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("received %s, cancelling", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}